@@ -1,28 +1,54 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/cloudflare/origin-ca-issuer/cmd/controller/options"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	"github.com/cloudflare/origin-ca-issuer/internal/logging"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/controllers"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/webhook"
+	"github.com/go-logr/logr"
 	"github.com/go-logr/zerologr"
 	"github.com/rs/zerolog"
 	"github.com/spf13/pflag"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// caReloadInterval is how often the Cloudflare API CA file is checked for changes.
+	caReloadInterval = 30 * time.Second
+
+	// webhookRenewInterval is how often the webhook serving certificate is checked
+	// for renewal.
+	webhookRenewInterval = time.Hour
 )
 
 func main() {
@@ -36,15 +62,27 @@ func main() {
 	zerologr.NameFieldName = "logger"
 	zerologr.NameSeparator = "/"
 
-	zl := zerolog.New(os.Stderr).With().Caller().Timestamp().Logger()
-	logf.SetLogger(zerologr.New(&zl))
-	log := logf.Log.WithName("origin-issuer").V(8)
-
 	if err := o.Validate(); err != nil {
-		log.Error(err, "error validating options")
+		logging.New(os.Stderr, logging.FormatJSON, 0).WithName("origin-issuer").Error(err, "error validating options")
 		os.Exit(1)
 	}
 
+	// The levels have been validated, so cannot fail to parse.
+	verbosity, _ := logging.ParseLevel(o.LogLevel)
+	root := logging.New(os.Stderr, o.LogFormat, verbosity)
+	logf.SetLogger(root)
+	log := root.WithName("origin-issuer")
+
+	controllerLog := func(name string) logr.Logger {
+		l := log
+		if level, ok := o.ControllerLogLevels[name]; ok {
+			v, _ := logging.ParseLevel(level)
+			l = logging.New(os.Stderr, o.LogFormat, v).WithName("origin-issuer")
+		}
+
+		return l.WithName("controllers").WithName(name)
+	}
+
 	scheme := runtime.NewScheme()
 	if err := clientgoscheme.AddToScheme(scheme); err != nil {
 		log.Error(err, "could not add to scheme")
@@ -68,9 +106,85 @@ func main() {
 	kubeCfg.QPS = o.KubernetesAPIQPS
 	kubeCfg.Burst = o.KubernetesAPIBurst
 
-	mgr, err := manager.New(kubeCfg, manager.Options{
+	scope, err := scopeFor(o)
+	if err != nil {
+		log.Error(err, "could not parse label selectors")
+		os.Exit(1)
+	}
+
+	mgrOpts := manager.Options{
 		Scheme: scheme,
-	})
+		Cache:  scope.CacheOptions(o.ClusterResourceNamespace),
+		Metrics: metricsserver.Options{
+			BindAddress: o.MetricsBindAddress,
+		},
+		HealthProbeBindAddress: o.HealthProbeBindAddress,
+
+		LeaderElection:          o.LeaderElect,
+		LeaderElectionID:        o.LeaderElectionID,
+		LeaderElectionNamespace: o.LeaderElectionNamespace,
+		LeaseDuration:           &o.LeaderElectionLeaseDuration,
+		RenewDeadline:           &o.LeaderElectionRenewDeadline,
+		RetryPeriod:             &o.LeaderElectionRetryPeriod,
+		// The manager is only stopped when the process exits, so the Lease can
+		// be released for a standby replica to take over immediately.
+		LeaderElectionReleaseOnCancel: true,
+	}
+
+	ctx := signals.SetupSignalHandler()
+
+	var rotator *webhook.CertificateRotator
+	var certWatcher *certwatcher.CertWatcher
+	if o.EnableWebhook {
+		// The manager's client is not usable until the manager starts, and the
+		// webhook server needs its certificate before then.
+		c, err := client.New(kubeCfg, client.Options{Scheme: scheme})
+		if err != nil {
+			log.Error(err, "could not create client")
+			os.Exit(1)
+		}
+
+		rotator = &webhook.CertificateRotator{
+			Client: c,
+			Options: webhook.CertificateOptions{
+				Namespace:                o.WebhookNamespace,
+				ServiceName:              o.WebhookServiceName,
+				SecretName:               o.WebhookSecretName,
+				WebhookConfigurationName: o.WebhookValidatingConfigurationName,
+				CertDir:                  o.WebhookCertDir,
+			},
+			Interval: webhookRenewInterval,
+			Clock:    clock.RealClock{},
+			Log:      log.WithName("webhook"),
+		}
+
+		if err := webhook.BootstrapCertificates(ctx, c, rotator.Options, rotator.Clock, rotator.Log); err != nil {
+			log.Error(err, "could not bootstrap webhook certificates")
+			os.Exit(1)
+		}
+
+		// Serve the certificate through a watcher, so renewed certificates are
+		// picked up without restarting.
+		certWatcher, err = certwatcher.New(
+			filepath.Join(o.WebhookCertDir, "tls.crt"),
+			filepath.Join(o.WebhookCertDir, "tls.key"),
+		)
+		if err != nil {
+			log.Error(err, "could not load webhook certificates")
+			os.Exit(1)
+		}
+
+		mgrOpts.WebhookServer = ctrlwebhook.NewServer(ctrlwebhook.Options{
+			Port: o.WebhookPort,
+			TLSOpts: []func(*tls.Config){
+				func(c *tls.Config) {
+					c.GetCertificate = certWatcher.GetCertificate
+				},
+			},
+		})
+	}
+
+	mgr, err := manager.New(kubeCfg, mgrOpts)
 	if err != nil {
 		log.Error(err, "could not create manager")
 		os.Exit(1)
@@ -78,39 +192,157 @@ func main() {
 
 	collection := provisioners.CollectionWith(nil)
 
+	roots, err := loadRoots(o.OriginCARootsFile)
+	if err != nil {
+		log.Error(err, "could not load Origin CA roots")
+		os.Exit(1)
+	}
+
+	var proxyURL *url.URL
+	if o.APIProxyURL != "" {
+		if proxyURL, err = url.Parse(o.APIProxyURL); err != nil {
+			log.Error(err, "could not parse proxy URL")
+			os.Exit(1)
+		}
+	}
+
+	transport, err := cfapi.NewTransport(cfapi.TransportOptions{
+		ProxyURL:       proxyURL,
+		CAFile:         o.APICAFile,
+		ReloadInterval: caReloadInterval,
+		Log:            log.WithName("cfapi"),
+	})
+	if err != nil {
+		log.Error(err, "could not load Cloudflare API certificate authorities")
+		os.Exit(1)
+	}
+
 	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: transport,
+	}
+	retry := cfapi.RetryOptions{
+		MaxRetries: o.APIMaxRetries,
+		MinBackoff: o.APIRetryMinBackoff,
+		MaxBackoff: o.APIRetryMaxBackoff,
 	}
-	f := cfapi.FactoryFunc(func(serviceKey []byte) (cfapi.Interface, error) {
-		return cfapi.New(serviceKey, cfapi.WithClient(httpClient)), nil
+	f := cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+		endpoint := o.APIEndpoint
+		if creds.Endpoint != "" {
+			endpoint = creds.Endpoint
+		}
+
+		withEndpoint, err := cfapi.WithEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		return cfapi.NewWithCredentials(creds,
+			cfapi.WithClient(httpClient),
+			withEndpoint,
+			cfapi.WithRetry(retry),
+			cfapi.WithTimeout(o.APITimeout),
+			cfapi.WithLogger(log.WithName("cfapi")),
+		)
 	})
 
+	issuerTypes := []client.Object{&v1.OriginIssuer{}}
+	if !scope.DisableClusterOriginIssuers {
+		issuerTypes = append(issuerTypes, &v1.ClusterOriginIssuer{})
+	}
+
+	for _, obj := range issuerTypes {
+		if err := mgr.GetFieldIndexer().IndexField(ctx, obj, controllers.SecretIndexField, controllers.IndexIssuerSecret); err != nil {
+			log.Error(err, "could not index issuer secrets")
+			os.Exit(1)
+		}
+
+		if err := mgr.GetFieldIndexer().IndexField(ctx, obj, controllers.ConfigMapIndexField, controllers.IndexIssuerConfigMap); err != nil {
+			log.Error(err, "could not index issuer configmaps")
+			os.Exit(1)
+		}
+	}
+
+	controllerOpts := controller.Options{
+		MaxConcurrentReconciles: o.MaxConcurrentReconciles,
+	}
+
+	rateLimit := v1.RateLimit{
+		RequestsPerMinute: o.IssuerRateLimit,
+		Burst:             o.IssuerRateLimitBurst,
+	}
+
+	originIssuerController := &controllers.OriginIssuerController{
+		Client:     mgr.GetClient(),
+		Clock:      clock.RealClock{},
+		Factory:    f,
+		Log:        controllerLog("OriginIssuer"),
+		Recorder:   mgr.GetEventRecorderFor("origin-ca-issuer"),
+		Collection: collection,
+		Roots:      roots,
+
+		ProbeInterval:  o.CredentialProbeInterval,
+		AllowEndpoints: o.AllowIssuerEndpoints,
+		RateLimit:      rateLimit,
+		Scope:          scope,
+	}
+
 	err = builder.
 		ControllerManagedBy(mgr).
+		WithOptions(controllerOpts).
 		For(&v1.OriginIssuer{}).
-		Complete(reconcile.AsReconciler(mgr.GetClient(), &controllers.OriginIssuerController{
+		Watches(&core.Secret{}, handler.EnqueueRequestsFromMapFunc(originIssuerController.IssuersForSecret)).
+		Watches(&core.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(originIssuerController.IssuersForConfigMap)).
+		Complete(originIssuerController)
+
+	if err != nil {
+		log.Error(err, "could not create origin issuer controller")
+		os.Exit(1)
+	}
+
+	if !scope.DisableClusterOriginIssuers {
+		clusterOriginIssuerController := &controllers.ClusterOriginIssuerController{
 			Client:     mgr.GetClient(),
 			Clock:      clock.RealClock{},
 			Factory:    f,
-			Log:        log.WithName("controllers").WithName("OriginIssuer"),
+			Log:        controllerLog("ClusterOriginIssuer"),
+			Recorder:   mgr.GetEventRecorderFor("origin-ca-issuer"),
 			Collection: collection,
-		}))
+			Roots:      roots,
 
-	if err != nil {
-		log.Error(err, "could not create origin issuer controller")
-		os.Exit(1)
+			ProbeInterval:            o.CredentialProbeInterval,
+			AllowEndpoints:           o.AllowIssuerEndpoints,
+			RateLimit:                rateLimit,
+			Scope:                    scope,
+			ClusterResourceNamespace: o.ClusterResourceNamespace,
+		}
+
+		err = builder.
+			ControllerManagedBy(mgr).
+			WithOptions(controllerOpts).
+			For(&v1.ClusterOriginIssuer{}).
+			Watches(&core.Secret{}, handler.EnqueueRequestsFromMapFunc(clusterOriginIssuerController.IssuersForSecret)).
+			Watches(&core.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(clusterOriginIssuerController.IssuersForConfigMap)).
+			Complete(clusterOriginIssuerController)
+
+		if err != nil {
+			log.Error(err, "could not create cluster origin issuer controller")
+			os.Exit(1)
+		}
 	}
 
 	err = builder.
 		ControllerManagedBy(mgr).
+		WithOptions(controllerOpts).
 		For(&certmanager.CertificateRequest{}).
 		Complete(reconcile.AsReconciler(mgr.GetClient(), &controllers.CertificateRequestController{
 			Client:     mgr.GetClient(),
-			Log:        log.WithName("controllers").WithName("CertificateRequest"),
+			Log:        controllerLog("CertificateRequest"),
+			Recorder:   mgr.GetEventRecorderFor("origin-ca-issuer"),
 			Collection: collection,
 
 			Clock:                  clock.RealClock{},
 			CheckApprovedCondition: !o.DisableApprovedCheck,
+			Scope:                  scope,
 		}))
 
 	if err != nil {
@@ -118,8 +350,116 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
+	err = builder.
+		ControllerManagedBy(mgr).
+		WithOptions(controllerOpts).
+		For(&certmanager.Certificate{}).
+		Owns(&certmanager.CertificateRequest{}).
+		Complete(reconcile.AsReconciler(mgr.GetClient(), &controllers.RevocationController{
+			Client:     mgr.GetClient(),
+			Log:        controllerLog("Revocation"),
+			Collection: collection,
+
+			Clock: clock.RealClock{},
+			Scope: scope,
+		}))
+
+	if err != nil {
+		log.Error(err, "could not create revocation controller")
+		os.Exit(1)
+	}
+
+	if o.EnableWebhook {
+		if err := mgr.Add(certWatcher); err != nil {
+			log.Error(err, "could not add webhook certificate watcher")
+			os.Exit(1)
+		}
+
+		if err := mgr.Add(rotator); err != nil {
+			log.Error(err, "could not add webhook certificate rotator")
+			os.Exit(1)
+		}
+
+		if err := webhook.SetupWithManager(mgr, &webhook.IssuerValidator{AllowEndpoints: o.AllowIssuerEndpoints}); err != nil {
+			log.Error(err, "could not create webhook")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		log.Error(err, "could not add health check")
+		os.Exit(1)
+	}
+
+	readyCheck := &controllers.ProvisionersReadyCheck{
+		Client:     mgr.GetClient(),
+		Collection: collection,
+		Elected:    mgr.Elected(),
+		Scope:      scope,
+	}
+	if err := mgr.AddReadyzCheck("provisioners", readyCheck.Check); err != nil {
+		log.Error(err, "could not add readiness check")
+		os.Exit(1)
+	}
+
+	if o.EnableWebhook {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			log.Error(err, "could not add readiness check")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.Start(ctx); err != nil {
 		log.Error(err, "could not start manager")
 		os.Exit(1)
 	}
 }
+
+// scopeFor returns the scope of the resources the controllers reconcile, from
+// the watch namespace and label selector options.
+func scopeFor(o *options.ControllerOptions) (controllers.Scope, error) {
+	scope := controllers.Scope{
+		Namespaces:                  o.WatchNamespaces,
+		DisableClusterOriginIssuers: o.DisableClusterOriginIssuers,
+	}
+
+	if o.IssuerLabelSelector != "" {
+		selector, err := labels.Parse(o.IssuerLabelSelector)
+		if err != nil {
+			return controllers.Scope{}, err
+		}
+
+		scope.IssuerSelector = selector
+	}
+
+	if o.CertificateRequestLabelSelector != "" {
+		selector, err := labels.Parse(o.CertificateRequestLabelSelector)
+		if err != nil {
+			return controllers.Scope{}, err
+		}
+
+		scope.CertificateRequestSelector = selector
+	}
+
+	return scope, nil
+}
+
+// loadRoots returns a pool of the PEM encoded certificates in the file at path,
+// or nil if path is empty, so provisioners use the embedded Origin CA roots.
+func loadRoots(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return roots, nil
+}
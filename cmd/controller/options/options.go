@@ -2,36 +2,213 @@ package options
 
 import (
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/cloudflare/origin-ca-issuer/internal/logging"
 	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type ControllerOptions struct {
+	LogLevel            string
+	LogFormat           string
+	ControllerLogLevels map[string]string
+
 	KubernetesAPIQPS   float32
 	KubernetesAPIBurst int
 
 	DisableApprovedCheck bool
+
+	MaxConcurrentReconciles int
+	IssuerRateLimit         int
+	IssuerRateLimitBurst    int
+
+	ClusterResourceNamespace string
+
+	WatchNamespaces                 []string
+	IssuerLabelSelector             string
+	CertificateRequestLabelSelector string
+	DisableClusterOriginIssuers     bool
+
+	LeaderElect                 bool
+	LeaderElectionID            string
+	LeaderElectionNamespace     string
+	LeaderElectionLeaseDuration time.Duration
+	LeaderElectionRenewDeadline time.Duration
+	LeaderElectionRetryPeriod   time.Duration
+
+	MetricsBindAddress     string
+	HealthProbeBindAddress string
+
+	APIEndpoint          string
+	APITimeout           time.Duration
+	APIProxyURL          string
+	APICAFile            string
+	AllowIssuerEndpoints bool
+
+	APIMaxRetries      int
+	APIRetryMinBackoff time.Duration
+	APIRetryMaxBackoff time.Duration
+
+	CredentialProbeInterval time.Duration
+
+	OriginCARootsFile string
+
+	EnableWebhook                      bool
+	WebhookPort                        int
+	WebhookCertDir                     string
+	WebhookNamespace                   string
+	WebhookServiceName                 string
+	WebhookSecretName                  string
+	WebhookValidatingConfigurationName string
 }
 
+// Controllers are the names of the controllers whose log level can be set with
+// --controller-log-level.
+var Controllers = []string{"OriginIssuer", "ClusterOriginIssuer", "CertificateRequest", "Revocation"}
+
 const (
+	defaultLogLevel  = "info"
+	defaultLogFormat = logging.FormatJSON
+
 	defaultKubernetesAPIQPS   float32 = 20
 	defaultKubernetesAPIBurst int     = 50
+
+	defaultMaxConcurrentReconciles int = 1
+	defaultIssuerRateLimitBurst    int = 1
+
+	defaultClusterResourceNamespace = "origin-ca-issuer"
+
+	defaultLeaderElectionID                          = "origin-ca-issuer-leader-election"
+	defaultLeaderElectionNamespace                   = "origin-ca-issuer"
+	defaultLeaderElectionLeaseDuration time.Duration = 15 * time.Second
+	defaultLeaderElectionRenewDeadline time.Duration = 10 * time.Second
+	defaultLeaderElectionRetryPeriod   time.Duration = 2 * time.Second
+
+	defaultMetricsBindAddress     = ":8080"
+	defaultHealthProbeBindAddress = ":8081"
+
+	defaultAPIEndpoint               = "https://api.cloudflare.com"
+	defaultAPITimeout  time.Duration = 30 * time.Second
+
+	defaultAPIMaxRetries      int           = 3
+	defaultAPIRetryMinBackoff time.Duration = time.Second
+	defaultAPIRetryMaxBackoff time.Duration = 30 * time.Second
+
+	defaultCredentialProbeInterval time.Duration = time.Hour
+
+	defaultWebhookPort                        int = 9443
+	defaultWebhookCertDir                         = "/tmp/k8s-webhook-server/serving-certs"
+	defaultWebhookNamespace                       = "origin-ca-issuer"
+	defaultWebhookServiceName                     = "origin-ca-issuer-webhook"
+	defaultWebhookSecretName                      = "origin-ca-issuer-webhook-tls"
+	defaultWebhookValidatingConfigurationName     = "origin-ca-issuer"
 )
 
 func NewControllerOptions() *ControllerOptions {
 	return &ControllerOptions{
+		LogLevel:  defaultLogLevel,
+		LogFormat: defaultLogFormat,
+
 		KubernetesAPIQPS:   defaultKubernetesAPIQPS,
 		KubernetesAPIBurst: defaultKubernetesAPIBurst,
+
+		MaxConcurrentReconciles: defaultMaxConcurrentReconciles,
+		IssuerRateLimitBurst:    defaultIssuerRateLimitBurst,
+
+		ClusterResourceNamespace: defaultClusterResourceNamespace,
+
+		LeaderElectionID:            defaultLeaderElectionID,
+		LeaderElectionNamespace:     defaultLeaderElectionNamespace,
+		LeaderElectionLeaseDuration: defaultLeaderElectionLeaseDuration,
+		LeaderElectionRenewDeadline: defaultLeaderElectionRenewDeadline,
+		LeaderElectionRetryPeriod:   defaultLeaderElectionRetryPeriod,
+
+		MetricsBindAddress:     defaultMetricsBindAddress,
+		HealthProbeBindAddress: defaultHealthProbeBindAddress,
+
+		APIEndpoint: defaultAPIEndpoint,
+		APITimeout:  defaultAPITimeout,
+
+		APIMaxRetries:      defaultAPIMaxRetries,
+		APIRetryMinBackoff: defaultAPIRetryMinBackoff,
+		APIRetryMaxBackoff: defaultAPIRetryMaxBackoff,
+
+		CredentialProbeInterval: defaultCredentialProbeInterval,
+
+		WebhookPort:                        defaultWebhookPort,
+		WebhookCertDir:                     defaultWebhookCertDir,
+		WebhookNamespace:                   defaultWebhookNamespace,
+		WebhookServiceName:                 defaultWebhookServiceName,
+		WebhookSecretName:                  defaultWebhookSecretName,
+		WebhookValidatingConfigurationName: defaultWebhookValidatingConfigurationName,
 	}
 }
 
 func (o *ControllerOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.LogLevel, "log-level", defaultLogLevel, "Log level: error, info, debug, or a verbosity, where higher verbosities log more messages.")
+	fs.StringVar(&o.LogFormat, "log-format", defaultLogFormat, "Log format: json or console.")
+	fs.StringToStringVar(&o.ControllerLogLevels, "controller-log-level", o.ControllerLogLevels, "Log level of a controller, overriding --log-level, such as CertificateRequest=debug. Controllers are "+strings.Join(Controllers, ", ")+".")
 	fs.Float32Var(&o.KubernetesAPIQPS, "kube-api-qps", defaultKubernetesAPIQPS, "Maximium queries-per-second of requests to the Kubernetes apiserver.")
 	fs.IntVar(&o.KubernetesAPIBurst, "kube-api-burst", defaultKubernetesAPIBurst, "Maximium queries-per-second burst of request send to the Kubernetes apiserver.")
 	fs.BoolVar(&o.DisableApprovedCheck, "disable-approved-check", o.DisableApprovedCheck, "Disables waiting for CertificateRequests to have an approved condition before signing.")
+	fs.IntVar(&o.MaxConcurrentReconciles, "max-concurrent-reconciles", defaultMaxConcurrentReconciles, "Maximum number of resources each controller reconciles concurrently.")
+	fs.IntVar(&o.IssuerRateLimit, "issuer-rate-limit", o.IssuerRateLimit, "Maximum sustained rate, in requests per minute, each issuer without a spec.rateLimit signs certificates at. Set to 0 to disable the limit.")
+	fs.IntVar(&o.IssuerRateLimitBurst, "issuer-rate-limit-burst", defaultIssuerRateLimitBurst, "Number of certificates each issuer without a spec.rateLimit may sign at once before being limited to --issuer-rate-limit.")
+	fs.StringVar(&o.ClusterResourceNamespace, "cluster-resource-namespace", defaultClusterResourceNamespace, "Namespace to read secrets referenced by ClusterOriginIssuers from.")
+	fs.StringSliceVar(&o.WatchNamespaces, "watch-namespace", o.WatchNamespaces, "Namespace to watch and reconcile resources in. Can be repeated. If unset, resources in all namespaces are reconciled.")
+	fs.StringVar(&o.IssuerLabelSelector, "issuer-label-selector", o.IssuerLabelSelector, "Label selector restricting the OriginIssuers and ClusterOriginIssuers that are reconciled.")
+	fs.StringVar(&o.CertificateRequestLabelSelector, "certificate-request-label-selector", o.CertificateRequestLabelSelector, "Label selector restricting the CertificateRequests and Certificates that are reconciled.")
+	fs.BoolVar(&o.DisableClusterOriginIssuers, "disable-cluster-origin-issuers", o.DisableClusterOriginIssuers, "Ignores ClusterOriginIssuers, so the controller does not need cluster-wide permissions to watch them.")
+	fs.BoolVar(&o.LeaderElect, "leader-elect", o.LeaderElect, "Elects a leader among replicas of the controller, so only one replica signs CertificateRequests at a time.")
+	fs.StringVar(&o.LeaderElectionID, "leader-election-id", defaultLeaderElectionID, "Name of the Lease used for leader election.")
+	fs.StringVar(&o.LeaderElectionNamespace, "leader-election-namespace", defaultLeaderElectionNamespace, "Namespace of the Lease used for leader election.")
+	fs.DurationVar(&o.LeaderElectionLeaseDuration, "leader-election-lease-duration", defaultLeaderElectionLeaseDuration, "Duration standby replicas wait before taking over leadership from a leader that stopped renewing its Lease.")
+	fs.DurationVar(&o.LeaderElectionRenewDeadline, "leader-election-renew-deadline", defaultLeaderElectionRenewDeadline, "Duration the leader retries renewing its Lease before giving up leadership.")
+	fs.DurationVar(&o.LeaderElectionRetryPeriod, "leader-election-retry-period", defaultLeaderElectionRetryPeriod, "Duration replicas wait between attempts to acquire or renew the Lease.")
+	fs.StringVar(&o.MetricsBindAddress, "metrics-bind-address", defaultMetricsBindAddress, "Address the metrics endpoint binds to. Set to 0 to disable the metrics endpoint.")
+	fs.StringVar(&o.HealthProbeBindAddress, "health-probe-bind-address", defaultHealthProbeBindAddress, "Address the /healthz and /readyz probe endpoints bind to. Set to 0 to disable the probe endpoints.")
+	fs.StringVar(&o.APIEndpoint, "api-endpoint", defaultAPIEndpoint, "Base URL of the Cloudflare API. Only the scheme and host are used.")
+	fs.DurationVar(&o.APITimeout, "api-timeout", defaultAPITimeout, "Timeout of each request to the Cloudflare API, including retries.")
+	fs.StringVar(&o.APIProxyURL, "api-proxy-url", o.APIProxyURL, "URL of the proxy requests to the Cloudflare API are sent through. If unset, the proxy is read from the HTTPS_PROXY and NO_PROXY environment variables.")
+	fs.StringVar(&o.APICAFile, "api-ca-file", o.APICAFile, "Path to a PEM file of certificate authorities trusted for connections to the Cloudflare API, in addition to the system roots. The file is reloaded when it changes.")
+	fs.BoolVar(&o.AllowIssuerEndpoints, "allow-issuer-endpoints", o.AllowIssuerEndpoints, "Accepts OriginIssuers and ClusterOriginIssuers overriding the Cloudflare API endpoint with spec.endpoint.")
+	fs.IntVar(&o.APIMaxRetries, "api-max-retries", defaultAPIMaxRetries, "Maximum number of times a transiently failed Cloudflare API request is retried. Set to 0 to disable retries.")
+	fs.DurationVar(&o.APIRetryMinBackoff, "api-retry-min-backoff", defaultAPIRetryMinBackoff, "Delay before the first retry of a Cloudflare API request, doubled on each following retry.")
+	fs.DurationVar(&o.APIRetryMaxBackoff, "api-retry-max-backoff", defaultAPIRetryMaxBackoff, "Maximum delay between retries of a Cloudflare API request, including delays requested with Retry-After.")
+	fs.DurationVar(&o.CredentialProbeInterval, "credential-probe-interval", defaultCredentialProbeInterval, "How often issuer credentials are verified against the Cloudflare API. Set to 0 to only verify credentials when an issuer or its Secret changes.")
+	fs.StringVar(&o.OriginCARootsFile, "origin-ca-roots-file", o.OriginCARootsFile, "Path to a PEM file of Origin CA root certificates that certificates returned by the Cloudflare API must chain to. If unset, the Origin CA roots embedded in the controller are used.")
+	fs.BoolVar(&o.EnableWebhook, "enable-webhook", o.EnableWebhook, "Serves a validating admission webhook for OriginIssuers and ClusterOriginIssuers.")
+	fs.IntVar(&o.WebhookPort, "webhook-port", defaultWebhookPort, "Port the validating admission webhook is served on.")
+	fs.StringVar(&o.WebhookCertDir, "webhook-cert-dir", defaultWebhookCertDir, "Directory the webhook serving certificate is written to.")
+	fs.StringVar(&o.WebhookNamespace, "webhook-namespace", defaultWebhookNamespace, "Namespace of the webhook Service and serving certificate Secret.")
+	fs.StringVar(&o.WebhookServiceName, "webhook-service-name", defaultWebhookServiceName, "Name of the Service fronting the webhook, used for the serving certificate's DNS names.")
+	fs.StringVar(&o.WebhookSecretName, "webhook-secret-name", defaultWebhookSecretName, "Name of the Secret the webhook serving certificate is stored in.")
+	fs.StringVar(&o.WebhookValidatingConfigurationName, "webhook-configuration-name", defaultWebhookValidatingConfigurationName, "Name of the ValidatingWebhookConfiguration to inject the webhook certificate authority into.")
 }
 
 func (o *ControllerOptions) Validate() error {
+	if _, err := logging.ParseLevel(o.LogLevel); err != nil {
+		return fmt.Errorf("invalid value for log-level: %w", err)
+	}
+
+	if err := logging.ValidateFormat(o.LogFormat); err != nil {
+		return fmt.Errorf("invalid value for log-format: %w", err)
+	}
+
+	for name, level := range o.ControllerLogLevels {
+		if !slices.Contains(Controllers, name) {
+			return fmt.Errorf("invalid value for controller-log-level: unknown controller %q, must be one of %s", name, strings.Join(Controllers, ", "))
+		}
+
+		if _, err := logging.ParseLevel(level); err != nil {
+			return fmt.Errorf("invalid value for controller-log-level: %s: %w", name, err)
+		}
+	}
+
 	if o.KubernetesAPIBurst <= 0 {
 		return fmt.Errorf("invalid value for kube-api-burst: %v must be higher than 0", o.KubernetesAPIBurst)
 	}
@@ -40,5 +217,105 @@ func (o *ControllerOptions) Validate() error {
 		return fmt.Errorf("invalid value for kube-api-qps: %v must be higher than 0", o.KubernetesAPIQPS)
 	}
 
+	if o.MaxConcurrentReconciles <= 0 {
+		return fmt.Errorf("invalid value for max-concurrent-reconciles: %v must be higher than 0", o.MaxConcurrentReconciles)
+	}
+
+	if o.IssuerRateLimit < 0 {
+		return fmt.Errorf("invalid value for issuer-rate-limit: %v must not be negative", o.IssuerRateLimit)
+	}
+
+	if o.IssuerRateLimitBurst < 0 {
+		return fmt.Errorf("invalid value for issuer-rate-limit-burst: %v must not be negative", o.IssuerRateLimitBurst)
+	}
+
+	if o.ClusterResourceNamespace == "" {
+		return fmt.Errorf("invalid value for cluster-resource-namespace: cannot be empty")
+	}
+
+	for _, ns := range o.WatchNamespaces {
+		if ns == "" {
+			return fmt.Errorf("invalid value for watch-namespace: cannot be empty")
+		}
+	}
+
+	if _, err := labels.Parse(o.IssuerLabelSelector); err != nil {
+		return fmt.Errorf("invalid value for issuer-label-selector: %w", err)
+	}
+
+	if _, err := labels.Parse(o.CertificateRequestLabelSelector); err != nil {
+		return fmt.Errorf("invalid value for certificate-request-label-selector: %w", err)
+	}
+
+	if o.LeaderElect {
+		if o.LeaderElectionID == "" {
+			return fmt.Errorf("invalid value for leader-election-id: cannot be empty")
+		}
+
+		if o.LeaderElectionNamespace == "" {
+			return fmt.Errorf("invalid value for leader-election-namespace: cannot be empty")
+		}
+
+		if o.LeaderElectionRetryPeriod <= 0 {
+			return fmt.Errorf("invalid value for leader-election-retry-period: %v must be higher than 0", o.LeaderElectionRetryPeriod)
+		}
+
+		if o.LeaderElectionRenewDeadline <= o.LeaderElectionRetryPeriod {
+			return fmt.Errorf("invalid value for leader-election-renew-deadline: %v must be higher than leader-election-retry-period", o.LeaderElectionRenewDeadline)
+		}
+
+		if o.LeaderElectionLeaseDuration <= o.LeaderElectionRenewDeadline {
+			return fmt.Errorf("invalid value for leader-election-lease-duration: %v must be higher than leader-election-renew-deadline", o.LeaderElectionLeaseDuration)
+		}
+	}
+
+	if u, err := url.Parse(o.APIEndpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid value for api-endpoint: %q must be an absolute https or http URL", o.APIEndpoint)
+	}
+
+	if o.APITimeout <= 0 {
+		return fmt.Errorf("invalid value for api-timeout: %v must be higher than 0", o.APITimeout)
+	}
+
+	if o.APIProxyURL != "" {
+		if u, err := url.Parse(o.APIProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid value for api-proxy-url: %q must be an absolute URL", o.APIProxyURL)
+		}
+	}
+
+	if o.APIMaxRetries < 0 {
+		return fmt.Errorf("invalid value for api-max-retries: %v must not be negative", o.APIMaxRetries)
+	}
+
+	if o.APIRetryMinBackoff <= 0 {
+		return fmt.Errorf("invalid value for api-retry-min-backoff: %v must be higher than 0", o.APIRetryMinBackoff)
+	}
+
+	if o.APIRetryMaxBackoff < o.APIRetryMinBackoff {
+		return fmt.Errorf("invalid value for api-retry-max-backoff: %v must not be lower than api-retry-min-backoff", o.APIRetryMaxBackoff)
+	}
+
+	if o.CredentialProbeInterval < 0 {
+		return fmt.Errorf("invalid value for credential-probe-interval: %v must not be negative", o.CredentialProbeInterval)
+	}
+
+	if o.EnableWebhook {
+		if o.WebhookPort <= 0 || o.WebhookPort > 65535 {
+			return fmt.Errorf("invalid value for webhook-port: %v must be between 1 and 65535", o.WebhookPort)
+		}
+
+		for _, f := range []struct{ name, value string }{
+			{"webhook-cert-dir", o.WebhookCertDir},
+			{"webhook-namespace", o.WebhookNamespace},
+			{"webhook-service-name", o.WebhookServiceName},
+			{"webhook-secret-name", o.WebhookSecretName},
+			{"webhook-configuration-name", o.WebhookValidatingConfigurationName},
+		} {
+			if f.value == "" {
+				return fmt.Errorf("invalid value for %s: cannot be empty", f.name)
+			}
+		}
+	}
+
 	return nil
 }
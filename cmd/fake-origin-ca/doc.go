@@ -0,0 +1,15 @@
+/*
+Fake-origin-ca runs a fake of the Cloudflare Origin CA API for local development.
+Certificates are signed by locally generated RSA and ECDSA certificate
+authorities, whose certificates can be written to a file with
+--root-certificates-file.
+
+Point the controller at the fake by using its address as the API endpoint.
+
+Faults can be injected by sending a POST request to /fake/faults with the
+query parameters method, status, code, message, retryAfter, latency, and times.
+For example, to rate limit the next three signing requests:
+
+	curl -X POST 'http://127.0.0.1:8080/fake/faults?method=POST&status=429&code=971&retryAfter=5&times=3'
+*/
+package main
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	"github.com/rs/zerolog"
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	var (
+		listenAddress        string
+		tlsCertFile          string
+		tlsKeyFile           string
+		rootCertificatesFile string
+		serviceKeys          []string
+		tokens               []string
+		zones                []string
+	)
+
+	fs := pflag.CommandLine
+	fs.StringVar(&listenAddress, "listen-address", "127.0.0.1:8080", "Address to serve the fake Origin CA API on.")
+	fs.StringVar(&tlsCertFile, "tls-cert-file", "", "File containing the certificate to serve TLS with. If unset, plain HTTP is served.")
+	fs.StringVar(&tlsKeyFile, "tls-key-file", "", "File containing the private key to serve TLS with.")
+	fs.StringVar(&rootCertificatesFile, "root-certificates-file", "", "File to write the PEM encoded root certificates of the fake certificate authorities to.")
+	fs.StringSliceVar(&serviceKeys, "service-key", nil, "Service key accepted by the fake API. May be repeated. If no service keys or tokens are given, any credentials are accepted.")
+	fs.StringSliceVar(&tokens, "token", nil, "API Token accepted by the fake API. May be repeated. If no service keys or tokens are given, any credentials are accepted.")
+	fs.StringSliceVar(&zones, "zone", nil, "Zone whose hostnames may be signed. May be repeated. If no zones are given, any hostname may be signed.")
+	_ = fs.Parse(os.Args[1:])
+
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
+	log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	srv, err := fakeapi.NewServer(
+		fakeapi.WithServiceKeys(serviceKeys...),
+		fakeapi.WithTokens(tokens...),
+		fakeapi.WithZones(zones...),
+	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not create fake API")
+	}
+
+	if rootCertificatesFile != "" {
+		if err := os.WriteFile(rootCertificatesFile, srv.RootPEM(), 0o644); err != nil {
+			log.Fatal().Err(err).Msg("could not write root certificates")
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/client/v4/certificates", srv)
+	mux.Handle("/client/v4/certificates/", srv)
+	mux.HandleFunc("/fake/faults", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		f, err := faultFrom(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		srv.Inject(f)
+		log.Info().Str("method", f.Method).Int("status", f.Status).Int("code", f.Code).Dur("latency", f.Latency).Int("times", f.Times).Msg("injected fault")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Info().Str("method", r.Method).Str("path", r.URL.Path).Msg("request")
+		mux.ServeHTTP(w, r)
+	})
+
+	server := &http.Server{
+		Addr:              listenAddress,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Info().Str("address", listenAddress).Msg("serving fake Origin CA API")
+
+	if tlsCertFile != "" {
+		err = server.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	log.Fatal().Err(err).Msg("could not serve fake API")
+}
+
+// faultFrom returns the fault described by the query parameters of r.
+func faultFrom(r *http.Request) (fakeapi.Fault, error) {
+	q := r.URL.Query()
+	f := fakeapi.Fault{
+		Method:  q.Get("method"),
+		Message: q.Get("message"),
+	}
+
+	ints := map[string]*int{
+		"status":     &f.Status,
+		"code":       &f.Code,
+		"retryAfter": &f.RetryAfter,
+		"times":      &f.Times,
+	}
+	for name, v := range ints {
+		if s := q.Get(name); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return f, err
+			}
+			*v = i
+		}
+	}
+
+	if s := q.Get("latency"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return f, err
+		}
+		f.Latency = d
+	}
+
+	return f, nil
+}
@@ -40,3 +40,33 @@ Create the name of the service account to use
     {{ default "default" .Values.controller.serviceAccount.name }}
 {{- end -}}
 {{- end -}}
+
+{{/*
+Rules for the namespaced resources reconciled by the controller.
+*/}}
+{{- define "origin-ca-issuer.namespacedRules" -}}
+- apiGroups: [""]
+  resources: ["events"]
+  verbs: ["create", "patch"]
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["get", "list", "watch"]
+- apiGroups: [""]
+  resources: ["configmaps"]
+  verbs: ["get", "list", "watch"]
+- apiGroups: ["cert-manager.io"]
+  resources: ["certificaterequests"]
+  verbs: ["get", "list", "patch", "update", "watch"]
+- apiGroups: ["cert-manager.io"]
+  resources: ["certificates"]
+  verbs: ["get", "list", "update", "watch"]
+- apiGroups: ["cert-manager.io"]
+  resources: ["certificaterequests/status"]
+  verbs: ["get", "patch", "update"]
+- apiGroups: ["cert-manager.k8s.cloudflare.com"]
+  resources: ["originissuers"]
+  verbs: ["create", "get", "list", "watch"]
+- apiGroups: ["cert-manager.k8s.cloudflare.com"]
+  resources: ["originissuers/status"]
+  verbs: ["get", "patch", "update"]
+{{- end -}}
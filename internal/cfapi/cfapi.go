@@ -4,27 +4,42 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/cloudflare/origin-ca-issuer/internal/metrics"
+	"github.com/go-logr/logr"
 )
 
 type Interface interface {
 	Sign(context.Context, *SignRequest) (*SignResponse, error)
+	Revoke(context.Context, string) (*RevokeResponse, error)
+	Verify(context.Context) error
 }
 
 type Client struct {
 	serviceKey []byte
+	token      []byte
 	client     *http.Client
 	endpoint   string
+	retry      RetryOptions
+	timeout    time.Duration
+	log        logr.Logger
 }
 
+// New returns a client authenticating with an Origin CA service key.
 func New(serviceKey []byte, options ...Options) *Client {
 	c := &Client{
 		serviceKey: serviceKey,
 		client:     http.DefaultClient,
 		endpoint:   "https://api.cloudflare.com/client/v4/certificates",
+		log:        logr.Discard(),
 	}
 
 	for _, opt := range options {
@@ -34,6 +49,29 @@ func New(serviceKey []byte, options ...Options) *Client {
 	return c
 }
 
+// NewWithToken returns a client authenticating with a Cloudflare API Token.
+func NewWithToken(token []byte, options ...Options) *Client {
+	c := New(nil, options...)
+	c.token = token
+
+	return c
+}
+
+// NewWithCredentials returns a client authenticating with whichever of the
+// service key or API Token is set in creds.
+func NewWithCredentials(creds Credentials, options ...Options) (*Client, error) {
+	switch {
+	case len(creds.ServiceKey) > 0 && len(creds.Token) > 0:
+		return nil, fmt.Errorf("only one of service key or token may be provided")
+	case len(creds.ServiceKey) > 0:
+		return New(creds.ServiceKey, options...), nil
+	case len(creds.Token) > 0:
+		return NewWithToken(creds.Token, options...), nil
+	}
+
+	return nil, fmt.Errorf("one of service key or token must be provided")
+}
+
 type Options func(c *Client)
 
 func WithClient(client *http.Client) Options {
@@ -72,6 +110,11 @@ type SignResponse struct {
 	CSR         string    `json:"csr"`
 }
 
+// RevokeResponse is the result of revoking an Origin CA certificate.
+type RevokeResponse struct {
+	Id string `json:"id"`
+}
+
 type APIResponse struct {
 	Success  bool            `json:"success"`
 	Errors   []APIError      `json:"errors"`
@@ -83,6 +126,11 @@ type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	RayID   string `json:"-"`
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+	// RetryAfter is the delay requested by the response's Retry-After header.
+	RetryAfter time.Duration `json:"-"`
 }
 
 func (a *APIError) Error() string {
@@ -95,13 +143,148 @@ func (c *Client) Sign(ctx context.Context, req *SignRequest) (*SignResponse, err
 		return nil, err
 	}
 
-	r, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewBuffer(p))
+	result, err := c.do(ctx, http.MethodPost, c.endpoint, p)
+	if err != nil {
+		return nil, err
+	}
+
+	signResp := SignResponse{}
+	if err := json.Unmarshal(result, &signResp); err != nil {
+		return nil, err
+	}
+
+	return &signResp, nil
+}
+
+// Revoke revokes the Origin CA certificate with the given ID.
+func (c *Client) Revoke(ctx context.Context, id string) (*RevokeResponse, error) {
+	result, err := c.do(ctx, http.MethodDelete, c.endpoint+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	revokeResp := RevokeResponse{}
+	if err := json.Unmarshal(result, &revokeResp); err != nil {
+		return nil, err
+	}
+
+	return &revokeResp, nil
+}
+
+// verifyProbeID is the ID of a certificate that does not exist, retrieved to
+// verify Origin CA service keys.
+const verifyProbeID = "0"
+
+// ErrTokenInactive is returned by Verify when the API Token is valid, but is not
+// active, such as when it has expired or been disabled.
+var ErrTokenInactive = errors.New("API Token is not active")
+
+// Verify checks the client's credentials are accepted by the Cloudflare API. API
+// Tokens are verified with the token verification endpoint, and must be active.
+// Origin CA service keys can only be used with the certificates API, so they are
+// verified by retrieving a certificate that does not exist: the API authenticates
+// the request before looking up the certificate, so only an authentication error
+// shows the service key was rejected. Transient errors are returned so the caller
+// can retry, and any other API error shows the service key was accepted.
+func (c *Client) Verify(ctx context.Context) error {
+	if len(c.token) > 0 {
+		result, err := c.do(ctx, http.MethodGet, strings.TrimSuffix(c.endpoint, "/certificates")+"/user/tokens/verify", nil)
+		if err != nil {
+			return err
+		}
+
+		verifyResp := struct {
+			Status string `json:"status"`
+		}{}
+		if err := json.Unmarshal(result, &verifyResp); err != nil {
+			return err
+		}
+
+		if verifyResp.Status != "active" {
+			return fmt.Errorf("%w: status is %q", ErrTokenInactive, verifyResp.Status)
+		}
+
+		return nil
+	}
+
+	_, err := c.do(ctx, http.MethodGet, c.endpoint+"/"+verifyProbeID, nil)
+	if err == nil {
+		return nil
+	}
+
+	if category := Classify(err); category == ErrorCategoryAuthRejected || category.Transient() {
+		return err
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return nil
+	}
+
+	return err
+}
+
+// do sends an authenticated request to the Cloudflare API, returning the result
+// of a successful response or the first API error. Transient failures are retried
+// according to the client's retry options, within the client's timeout.
+func (c *Client) do(ctx context.Context, method, endpoint string, body []byte) (json.RawMessage, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	for retry := 0; ; retry++ {
+		result, err := c.send(ctx, method, endpoint, body)
+		if err == nil || retry >= c.retry.MaxRetries || !retryable(method, err) {
+			return result, err
+		}
+
+		var retryAfter time.Duration
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			retryAfter = apiErr.RetryAfter
+		}
+
+		delay := c.retry.backoff(retry, retryAfter)
+
+		// Give up now rather than wait for a retry the deadline does not leave time for.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
+
+		c.log.Info("retrying Cloudflare API request", "method", method, "attempt", retry+1, "maxRetries", c.retry.MaxRetries, "delay", delay, "error", err.Error())
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-t.C:
+		}
+	}
+}
+
+// send makes a single attempt at an authenticated request to the Cloudflare API.
+func (c *Client) send(ctx context.Context, method, endpoint string, body []byte) (result json.RawMessage, err error) {
+	start := time.Now()
+	status := "error"
+	defer func() {
+		metrics.APIRequestDuration.WithLabelValues(method, status, errorCode(err)).Observe(time.Since(start).Seconds())
+	}()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
 	if err != nil {
 		return nil, err
 	}
 
 	r.Header.Add("User-Agent", "github.com/cloudflare/origin-ca-issuer")
-	r.Header.Add("X-Auth-User-Service-Key", string(c.serviceKey))
+	c.authenticate(r)
 
 	resp, err := c.client.Do(r)
 	if err != nil {
@@ -109,25 +292,62 @@ func (c *Client) Sign(ctx context.Context, req *SignRequest) (*SignResponse, err
 	}
 	defer resp.Body.Close()
 
+	status = strconv.Itoa(resp.StatusCode)
 	rayID := resp.Header.Get("CF-Ray")
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 
 	api := APIResponse{}
 	if err := json.NewDecoder(resp.Body).Decode(&api); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, &APIError{
+				Message:    fmt.Sprintf("unexpected response with status %d", resp.StatusCode),
+				RayID:      rayID,
+				StatusCode: resp.StatusCode,
+				RetryAfter: retryAfter,
+			}
+		}
+
 		return nil, err
 	}
 
 	if !api.Success {
+		if len(api.Errors) == 0 {
+			return nil, &APIError{
+				Message:    fmt.Sprintf("unsuccessful response with status %d", resp.StatusCode),
+				RayID:      rayID,
+				StatusCode: resp.StatusCode,
+				RetryAfter: retryAfter,
+			}
+		}
+
 		err := &api.Errors[0]
 		err.RayID = rayID
+		err.StatusCode = resp.StatusCode
+		err.RetryAfter = retryAfter
 		return nil, err
 	}
 
-	signResp := SignResponse{}
-	if err := json.Unmarshal(api.Result, &signResp); err != nil {
-		return nil, err
+	return api.Result, nil
+}
+
+// errorCode returns the API error code of err, if any, for use as a metric label.
+func errorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return strconv.Itoa(apiErr.Code)
 	}
 
-	return &signResp, nil
+	return ""
+}
+
+// authenticate adds the authentication headers for the client's credentials to r.
+func (c *Client) authenticate(r *http.Request) {
+	if len(c.token) > 0 {
+		r.Header.Add("Authorization", "Bearer "+string(c.token))
+		return
+	}
+
+	r.Header.Add("X-Auth-User-Service-Key", string(c.serviceKey))
 }
 
 // adapted from http://choly.ca/post/go-json-marshalling/
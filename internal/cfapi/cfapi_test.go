@@ -3,9 +3,11 @@ package cfapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -154,6 +156,89 @@ func TestSign(t *testing.T) {
 
 }
 
+func TestSign_Authentication(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  func(...Options) *Client
+		headers map[string]string
+	}{
+		{
+			name: "service key",
+			client: func(opts ...Options) *Client {
+				return New([]byte("v1.0-FFFF-FFFF"), opts...)
+			},
+			headers: map[string]string{
+				"X-Auth-User-Service-Key": "v1.0-FFFF-FFFF",
+				"Authorization":           "",
+			},
+		},
+		{
+			name: "api token",
+			client: func(opts ...Options) *Client {
+				return NewWithToken([]byte("api-token"), opts...)
+			},
+			headers: map[string]string{
+				"X-Auth-User-Service-Key": "",
+				"Authorization":           "Bearer api-token",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					if diff := cmp.Diff(r.Header.Get(k), v); diff != "" {
+						t.Errorf("header %s diff: (-want +got)\n%s", k, diff)
+					}
+				}
+
+				fmt.Fprintln(w, `{"success": true, "errors": [], "messages": [], "result": {"expires_on": "2020-12-25T06:27:00Z"}}`)
+			}))
+			defer ts.Close()
+
+			client := tt.client(WithClient(ts.Client()), Must(WithEndpoint(ts.URL)))
+			if _, err := client.Sign(context.Background(), &SignRequest{}); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestNewWithCredentials(t *testing.T) {
+	tests := []struct {
+		name  string
+		creds Credentials
+		error string
+	}{
+		{name: "service key", creds: Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")}},
+		{name: "token", creds: Credentials{Token: []byte("api-token")}},
+		{name: "both", creds: Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF"), Token: []byte("api-token")}, error: "only one of service key or token may be provided"},
+		{name: "neither", creds: Credentials{}, error: "one of service key or token must be provided"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWithCredentials(tt.creds)
+			if tt.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+				t.Fatalf("diff: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
 func Must(opt Options, err error) Options {
 	if err != nil {
 		panic("option constructo returned error " + err.Error())
@@ -161,3 +246,416 @@ func Must(opt Options, err error) Options {
 
 	return opt
 }
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		response *RevokeResponse
+		error    string
+	}{
+		{
+			name: "API success",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete || r.URL.Path != "/client/v4/certificates/9001" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+
+				fmt.Fprintln(w, `{"success": true, "errors": [], "messages": [], "result": {"id": "9001"}}`)
+			}),
+			response: &RevokeResponse{Id: "9001"},
+		},
+		{
+			name: "API error",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("cf-ray", "0123456789abcdef-ABC")
+				fmt.Fprintln(w, `{"success": false, "errors": [{"code": 9001, "message": "Over Nine Thousand!"}], "messages": [], "result": null}`)
+			}),
+			error: "Cloudflare API Error code=9001 message=Over Nine Thousand! ray_id=0123456789abcdef-ABC",
+		},
+		{
+			name: "API error without details",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintln(w, `{"success": false, "errors": [], "messages": [], "result": null}`)
+			}),
+			error: "Cloudflare API Error code=0 message=unsuccessful response with status 404 ray_id=",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewTLSServer(tt.handler)
+			defer ts.Close()
+
+			client := New([]byte("v1.0-FFFF-FFFF"),
+				WithClient(ts.Client()),
+				Must(WithEndpoint(ts.URL)),
+			)
+			resp, err := client.Revoke(context.Background(), "9001")
+
+			if diff := cmp.Diff(resp, tt.response); diff != "" {
+				t.Fatalf("diff: (-want +got)\n%s", diff)
+			}
+
+			if tt.error != "" {
+				if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+					t.Fatalf("diff: (-want +got)\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestDo_Retry(t *testing.T) {
+	success := `{"success": true, "errors": [], "messages": [], "result": {"id": "9001", "expires_on": "2020-12-25T06:27:00Z"}}`
+
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		attempts int
+		error    string
+	}{
+		{
+			name:     "retries rate limited sign",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			attempts: 2,
+		},
+		{
+			name:     "retries unavailable sign",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			attempts: 3,
+		},
+		{
+			name:     "does not retry sign after internal error",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			attempts: 1,
+			error:    "Cloudflare API Error code=0 message=unexpected response with status 500 ray_id=",
+		},
+		{
+			name:     "retries revoke after internal error",
+			method:   http.MethodDelete,
+			statuses: []int{http.StatusInternalServerError, http.StatusGatewayTimeout, http.StatusOK},
+			attempts: 3,
+		},
+		{
+			name:     "does not retry bad request",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			attempts: 1,
+			error:    "Cloudflare API Error code=0 message=unexpected response with status 400 ray_id=",
+		},
+		{
+			name:     "gives up after max retries",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			attempts: 3,
+			error:    "Cloudflare API Error code=0 message=unexpected response with status 429 ray_id=",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts]
+				attempts++
+
+				if status != http.StatusOK {
+					w.Header().Add("Retry-After", "0")
+					w.WriteHeader(status)
+					fmt.Fprintln(w, "<html>error</html>")
+					return
+				}
+
+				fmt.Fprintln(w, success)
+			}))
+			defer ts.Close()
+
+			client := New([]byte("v1.0-FFFF-FFFF"),
+				WithClient(ts.Client()),
+				Must(WithEndpoint(ts.URL)),
+				WithRetry(RetryOptions{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}),
+			)
+
+			var err error
+			if tt.method == http.MethodDelete {
+				_, err = client.Revoke(context.Background(), "9001")
+			} else {
+				_, err = client.Sign(context.Background(), &SignRequest{})
+			}
+
+			if diff := cmp.Diff(attempts, tt.attempts); diff != "" {
+				t.Fatalf("attempts diff: (-got +want)\n%s", diff)
+			}
+
+			if tt.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDo_Timeout(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		w.Header().Add("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintln(w, "<html>error</html>")
+	}))
+	defer ts.Close()
+
+	client := New([]byte("v1.0-FFFF-FFFF"),
+		WithClient(ts.Client()),
+		Must(WithEndpoint(ts.URL)),
+		WithRetry(RetryOptions{MaxRetries: 5, MinBackoff: 10 * time.Millisecond, MaxBackoff: 5 * time.Second}),
+		WithTimeout(500*time.Millisecond),
+	)
+
+	start := time.Now()
+	_, err := client.Sign(context.Background(), &SignRequest{})
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected the request to give up within the timeout, took %s", elapsed)
+	}
+
+	if diff := cmp.Diff(attempts, 1); diff != "" {
+		t.Fatalf("attempts diff: (-got +want)\n%s", diff)
+	}
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if diff := cmp.Diff(err.Error(), "Cloudflare API Error code=0 message=unexpected response with status 429 ray_id="); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestRetryOptions_Backoff(t *testing.T) {
+	o := RetryOptions{MinBackoff: time.Second, MaxBackoff: 8 * time.Second}
+
+	tests := []struct {
+		name       string
+		retry      int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{name: "first retry", retry: 0, min: 500 * time.Millisecond, max: time.Second},
+		{name: "exponential", retry: 2, min: 2 * time.Second, max: 4 * time.Second},
+		{name: "capped", retry: 10, min: 4 * time.Second, max: 8 * time.Second},
+		{name: "honors retry-after", retry: 0, retryAfter: 5 * time.Second, min: 5 * time.Second, max: 5 * time.Second},
+		{name: "caps retry-after", retry: 0, retryAfter: time.Minute, min: 8 * time.Second, max: 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := o.backoff(tt.retry, tt.retryAfter); d < tt.min || d > tt.max {
+					t.Fatalf("expected backoff between %s and %s, got %s", tt.min, tt.max, d)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, time.December, 25, 6, 27, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "empty", value: "", expected: 0},
+		{name: "seconds", value: "30", expected: 30 * time.Second},
+		{name: "http date", value: "Fri, 25 Dec 2020 06:28:00 GMT", expected: time.Minute},
+		{name: "past http date", value: "Fri, 25 Dec 2020 06:26:00 GMT", expected: 0},
+		{name: "invalid", value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(parseRetryAfter(tt.value, now), tt.expected); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		expected  ErrorCategory
+		transient bool
+	}{
+		{name: "rate limited code", err: &APIError{Code: 971}, expected: ErrorCategoryRateLimited, transient: true},
+		{name: "rate limited status", err: &APIError{StatusCode: http.StatusTooManyRequests}, expected: ErrorCategoryRateLimited, transient: true},
+		{name: "invalid csr", err: &APIError{Code: 1001, StatusCode: http.StatusBadRequest}, expected: ErrorCategoryInvalidCSR},
+		{name: "hostname not in account", err: &APIError{Code: 1010, StatusCode: http.StatusBadRequest}, expected: ErrorCategoryHostnameNotInAccount},
+		{name: "auth code", err: &APIError{Code: 10000, StatusCode: http.StatusBadRequest}, expected: ErrorCategoryAuthRejected},
+		{name: "auth status", err: &APIError{StatusCode: http.StatusForbidden}, expected: ErrorCategoryAuthRejected},
+		{name: "upstream unavailable", err: &APIError{StatusCode: http.StatusBadGateway}, expected: ErrorCategoryUpstreamUnavailable, transient: true},
+		{name: "wrapped api error", err: fmt.Errorf("unable to sign request: %w", &APIError{StatusCode: http.StatusServiceUnavailable}), expected: ErrorCategoryUpstreamUnavailable, transient: true},
+		{name: "deadline exceeded", err: fmt.Errorf("unable to sign request: %w", context.DeadlineExceeded), expected: ErrorCategoryUpstreamUnavailable, transient: true},
+		{name: "network error", err: &url.Error{Op: "Post", URL: "https://api.cloudflare.com", Err: errors.New("connection reset by peer")}, expected: ErrorCategoryUpstreamUnavailable, transient: true},
+		{name: "unknown api error", err: &APIError{Code: 9001, StatusCode: http.StatusBadRequest}, expected: ErrorCategoryUnknown},
+		{name: "other error", err: errors.New("failed to decode CSR for signing"), expected: ErrorCategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.err)
+			if diff := cmp.Diff(got, tt.expected); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+
+			if got.Transient() != tt.transient {
+				t.Fatalf("expected transient %t, got %t", tt.transient, got.Transient())
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    bool
+		path     string
+		status   int
+		response string
+		error    string
+		category ErrorCategory
+	}{
+		{
+			name:     "active token",
+			token:    true,
+			path:     "/client/v4/user/tokens/verify",
+			status:   http.StatusOK,
+			response: `{"success": true, "errors": [], "messages": [], "result": {"id": "ed17574386854bf78a67040be0a770b0", "status": "active"}}`,
+		},
+		{
+			name:     "disabled token",
+			token:    true,
+			path:     "/client/v4/user/tokens/verify",
+			status:   http.StatusOK,
+			response: `{"success": true, "errors": [], "messages": [], "result": {"id": "ed17574386854bf78a67040be0a770b0", "status": "disabled"}}`,
+			error:    `API Token is not active: status is "disabled"`,
+			category: ErrorCategoryAuthRejected,
+		},
+		{
+			name:     "service key",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusNotFound,
+			response: `{"success": false, "errors": [{"code": 1004, "message": "Certificate not found"}], "messages": [], "result": null}`,
+		},
+		{
+			name:     "rejected service key",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusForbidden,
+			response: `{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}], "messages": [], "result": null}`,
+			error:    "Cloudflare API Error code=10000 message=Authentication error ray_id=",
+			category: ErrorCategoryAuthRejected,
+		},
+		{
+			name:     "unauthorized service key",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusUnauthorized,
+			response: `{"success": false, "errors": [], "messages": [], "result": null}`,
+			error:    "Cloudflare API Error code=0 message=unsuccessful response with status 401 ray_id=",
+			category: ErrorCategoryAuthRejected,
+		},
+		{
+			name:     "rate limited service key",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusTooManyRequests,
+			response: `{"success": false, "errors": [{"code": 971, "message": "Please wait and consider throttling your request speed"}], "messages": [], "result": null}`,
+			error:    "Cloudflare API Error code=971 message=Please wait and consider throttling your request speed ray_id=",
+			category: ErrorCategoryRateLimited,
+		},
+		{
+			name:     "unavailable service key",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusServiceUnavailable,
+			response: `<html>error</html>`,
+			error:    "Cloudflare API Error code=0 message=unexpected response with status 503 ray_id=",
+			category: ErrorCategoryUpstreamUnavailable,
+		},
+		{
+			name:     "unexpected service key error",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusBadRequest,
+			response: `{"success": false, "errors": [{"code": 1003, "message": "Invalid or missing zone id."}], "messages": [], "result": null}`,
+		},
+		{
+			name:     "service key certificate found",
+			path:     "/client/v4/certificates/0",
+			status:   http.StatusOK,
+			response: `{"success": true, "errors": [], "messages": [], "result": {"id": "0"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != tt.path {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.response)
+			}))
+			defer ts.Close()
+
+			opts := []Options{WithClient(ts.Client()), Must(WithEndpoint(ts.URL))}
+
+			client := New([]byte("v1.0-FFFF-FFFF"), opts...)
+			if tt.token {
+				client = NewWithToken([]byte("api-token"), opts...)
+			}
+
+			err := client.Verify(context.Background())
+			if tt.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+				t.Fatalf("error diff: (-got +want)\n%s", diff)
+			}
+
+			if diff := cmp.Diff(Classify(err), tt.category); diff != "" {
+				t.Fatalf("category diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
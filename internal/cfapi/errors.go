@@ -0,0 +1,109 @@
+package cfapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ErrorCategory classifies errors returned by the Cloudflare API by how they
+// should be handled.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryUnknown is an error that could not be classified, and is
+	// treated as permanent.
+	ErrorCategoryUnknown ErrorCategory = "Unknown"
+
+	// ErrorCategoryAuthRejected is an error where the API rejected the
+	// service key or API Token.
+	ErrorCategoryAuthRejected ErrorCategory = "AuthRejected"
+
+	// ErrorCategoryInvalidCSR is an error where the API could not parse or
+	// accept the certificate signing request.
+	ErrorCategoryInvalidCSR ErrorCategory = "InvalidCSR"
+
+	// ErrorCategoryHostnameNotInAccount is an error where a requested hostname
+	// does not belong to a zone the credentials have access to.
+	ErrorCategoryHostnameNotInAccount ErrorCategory = "HostnameNotInAccount"
+
+	// ErrorCategoryRateLimited is an error where the request was rate limited.
+	ErrorCategoryRateLimited ErrorCategory = "RateLimited"
+
+	// ErrorCategoryUpstreamUnavailable is an error where the API could not be
+	// reached or was unable to process the request.
+	ErrorCategoryUpstreamUnavailable ErrorCategory = "UpstreamUnavailable"
+)
+
+// Cloudflare API error codes used to classify errors.
+const (
+	errorCodeRateLimited        = 971
+	errorCodeInvalidCSR         = 1001
+	errorCodeCSRParse           = 1002
+	errorCodeHostnameValidation = 1010
+	errorCodeMissingAuth        = 9106
+	errorCodeUnauthorized       = 9109
+	errorCodeAuthentication     = 10000
+)
+
+// Transient returns true if a request failing with an error of this category
+// may succeed if retried later.
+func (c ErrorCategory) Transient() bool {
+	switch c {
+	case ErrorCategoryRateLimited, ErrorCategoryUpstreamUnavailable:
+		return true
+	}
+
+	return false
+}
+
+// Classify returns the category of an error returned by the client, based on
+// the API error code, the HTTP status code, and context or network errors.
+func Classify(err error) ErrorCategory {
+	if err == nil {
+		return ErrorCategoryUnknown
+	}
+
+	if errors.Is(err, ErrTokenInactive) {
+		return ErrorCategoryAuthRejected
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case errorCodeRateLimited:
+			return ErrorCategoryRateLimited
+		case errorCodeInvalidCSR, errorCodeCSRParse:
+			return ErrorCategoryInvalidCSR
+		case errorCodeHostnameValidation:
+			return ErrorCategoryHostnameNotInAccount
+		case errorCodeMissingAuth, errorCodeUnauthorized, errorCodeAuthentication:
+			return ErrorCategoryAuthRejected
+		}
+
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorCategoryRateLimited
+		case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+			return ErrorCategoryAuthRejected
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return ErrorCategoryUpstreamUnavailable
+		}
+
+		return ErrorCategoryUnknown
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorCategoryUpstreamUnavailable
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return ErrorCategoryUpstreamUnavailable
+	}
+
+	return ErrorCategoryUnknown
+}
@@ -1,11 +1,25 @@
 package cfapi
 
+// Credentials holds the secret material used to authenticate with the Cloudflare
+// API, and the endpoint it is sent to. Exactly one of ServiceKey or Token should
+// be set.
+type Credentials struct {
+	// ServiceKey is an Origin CA service key, sent as X-Auth-User-Service-Key.
+	ServiceKey []byte
+
+	// Token is a Cloudflare API Token, sent as a bearer token.
+	Token []byte
+
+	// Endpoint overrides the base URL of the Cloudflare API, if set.
+	Endpoint string
+}
+
 type Factory interface {
-	APIWith([]byte) (Interface, error)
+	APIWith(Credentials) (Interface, error)
 }
 
-type FactoryFunc func([]byte) (Interface, error)
+type FactoryFunc func(Credentials) (Interface, error)
 
-func (f FactoryFunc) APIWith(serviceKey []byte) (Interface, error) {
-	return f(serviceKey)
+func (f FactoryFunc) APIWith(creds Credentials) (Interface, error) {
+	return f(creds)
 }
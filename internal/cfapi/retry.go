@@ -0,0 +1,144 @@
+package cfapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// RetryOptions configures how the client retries requests that failed
+// transiently, such as when rate limited or the API is briefly unavailable.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of times a request is retried after
+	// the initial attempt. Zero disables retries.
+	MaxRetries int
+
+	// MinBackoff is the delay before the first retry. Each following retry
+	// doubles the delay, with jitter.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries, including any delay
+	// requested by the API with a Retry-After header.
+	MaxBackoff time.Duration
+}
+
+// WithRetry configures the client to retry transient failures.
+func WithRetry(retry RetryOptions) Options {
+	return func(c *Client) {
+		c.retry = retry
+	}
+}
+
+// WithTimeout bounds each request to the Cloudflare API, including its retries.
+// Zero disables the timeout.
+func WithTimeout(timeout time.Duration) Options {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithLogger configures the logger used to report retried requests.
+func WithLogger(log logr.Logger) Options {
+	return func(c *Client) {
+		c.log = log
+	}
+}
+
+// backoff returns the delay before the given retry, counting from zero. The
+// delay grows exponentially from MinBackoff with equal jitter, is extended to
+// honor retryAfter, and is capped at MaxBackoff.
+func (o RetryOptions) backoff(retry int, retryAfter time.Duration) time.Duration {
+	d := o.MinBackoff
+	for i := 0; i < retry && d < o.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > o.MaxBackoff {
+		d = o.MaxBackoff
+	}
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	if retryAfter > d {
+		d = retryAfter
+	}
+
+	if d > o.MaxBackoff {
+		d = o.MaxBackoff
+	}
+
+	return d
+}
+
+// retryable returns true if the failed request is safe to send again. Requests
+// rejected before being processed, because of rate limiting or an unavailable
+// upstream, can always be retried. Requests that may have been processed, such
+// as those that timed out or had their connection reset, are only retried if
+// the method is idempotent, so a certificate is never signed twice.
+func retryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+			return true
+		case http.StatusInternalServerError, http.StatusGatewayTimeout:
+			return idempotent(method)
+		}
+
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return idempotent(method)
+	}
+
+	return false
+}
+
+// idempotent returns true if sending a request with method more than once has
+// the same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodPut:
+		return true
+	}
+
+	return false
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given
+// either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,104 @@
+package testingcfapi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Authority is a local certificate authority, standing in for an Origin CA root.
+type Authority struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewAuthority returns a newly generated certificate authority with an RSA key
+// if kind is "RSA", or an ECDSA key otherwise.
+func NewAuthority(kind string, now time.Time) (*Authority, error) {
+	var key crypto.Signer
+	var err error
+	switch kind {
+	case "RSA":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization:       []string{"CloudFlare, Inc."},
+			OrganizationalUnit: []string{"CloudFlare Origin SSL " + kind + " Certificate Authority (Fake)"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(20 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authority{cert: cert, key: key}, nil
+}
+
+// PEM returns the PEM encoded certificate of the authority.
+func (a *Authority) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw})
+}
+
+// SignCSR returns a PEM encoded certificate for the public key of the PEM encoded
+// CSR, valid for hostnames until notAfter.
+func (a *Authority) SignCSR(csrPEM []byte, hostnames []string, notAfter time.Time) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		return nil, errors.New("no certificate request found")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := a.sign(&x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			Organization:       []string{"CloudFlare, Inc."},
+			OrganizationalUnit: []string{"CloudFlare Origin CA"},
+			CommonName:         "CloudFlare Origin Certificate",
+		},
+		DNSNames:    hostnames,
+		NotBefore:   a.cert.NotBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func (a *Authority) sign(tmpl *x509.Certificate, pub interface{}) ([]byte, error) {
+	return x509.CreateCertificate(rand.Reader, tmpl, a.cert, pub, a.key)
+}
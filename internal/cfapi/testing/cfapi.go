@@ -8,8 +8,31 @@ import (
 
 type FakeClient struct {
 	Response *cfapi.SignResponse
+
+	// Error, if set, is returned by Sign instead of Response.
+	Error error
+
+	// Revoked records the IDs of certificates passed to Revoke.
+	Revoked []string
+
+	// VerifyError, if set, is returned by Verify.
+	VerifyError error
 }
 
 func (f *FakeClient) Sign(context.Context, *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+	if f.Error != nil {
+		return nil, f.Error
+	}
+
 	return f.Response, nil
 }
+
+func (f *FakeClient) Revoke(_ context.Context, id string) (*cfapi.RevokeResponse, error) {
+	f.Revoked = append(f.Revoked, id)
+
+	return &cfapi.RevokeResponse{Id: id}, nil
+}
+
+func (f *FakeClient) Verify(context.Context) error {
+	return f.VerifyError
+}
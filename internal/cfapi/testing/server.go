@@ -0,0 +1,476 @@
+package testingcfapi
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+)
+
+// Error codes returned by the fake Origin CA API.
+const (
+	ErrorCodeRateLimited        = 971
+	ErrorCodeInvalidCSR         = 1001
+	ErrorCodeInvalidValidity    = 1003
+	ErrorCodeInvalidRequestType = 1004
+	ErrorCodeNotFound           = 1005
+	ErrorCodeInvalidHostnames   = 1009
+	ErrorCodeHostnameValidation = 1010
+	ErrorCodeAuthentication     = 10000
+)
+
+// expirationFormat is the format the Origin CA API uses for expires_on.
+const expirationFormat = "2006-01-02 15:04:05 -0700 MST"
+
+// Certificate is an Origin CA certificate as returned by the fake API.
+type Certificate struct {
+	Id          string   `json:"id"`
+	Certificate string   `json:"certificate"`
+	Hostnames   []string `json:"hostnames"`
+	Expiration  string   `json:"expires_on"`
+	Type        string   `json:"request_type"`
+	Validity    int      `json:"requested_validity"`
+	CSR         string   `json:"csr"`
+	RevokedAt   string   `json:"revoked_at,omitempty"`
+}
+
+// Fault is an error injected into responses of the fake API.
+type Fault struct {
+	// Method restricts the fault to requests with the given HTTP method. An
+	// empty method matches every request.
+	Method string
+
+	// Status is the HTTP status code of the response. If zero, the request is
+	// only delayed by Latency and then handled normally.
+	Status int
+
+	// Code and Message are returned as the API error of the response.
+	Code    int
+	Message string
+
+	// RetryAfter is sent as the Retry-After header of the response, in seconds.
+	RetryAfter int
+
+	// Latency delays the response.
+	Latency time.Duration
+
+	// Times is the number of requests the fault applies to. If zero, the fault
+	// applies to a single request.
+	Times int
+}
+
+// Server is a fake of the Cloudflare Origin CA API at /client/v4/certificates.
+// It signs certificate requests with local RSA and ECDSA certificate authorities,
+// validates requests the way the API does, and supports listing, retrieving, and
+// revoking the certificates it has signed.
+type Server struct {
+	serviceKeys map[string]bool
+	tokens      map[string]bool
+	zones       []string
+
+	rsa *Authority
+	ecc *Authority
+
+	mu           sync.Mutex
+	certificates map[string]*Certificate
+	faults       []*Fault
+	serial       int64
+	now          func() time.Time
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithServiceKeys restricts the service keys accepted by the server. By default,
+// any non-empty service key or token is accepted.
+func WithServiceKeys(keys ...string) ServerOption {
+	return func(s *Server) {
+		for _, key := range keys {
+			s.serviceKeys[key] = true
+		}
+	}
+}
+
+// WithTokens restricts the API Tokens accepted by the server. By default, any
+// non-empty service key or token is accepted.
+func WithTokens(tokens ...string) ServerOption {
+	return func(s *Server) {
+		for _, token := range tokens {
+			s.tokens[token] = true
+		}
+	}
+}
+
+// WithZones restricts the hostnames that may be signed to the given zones and
+// their subdomains. By default, any hostname may be signed.
+func WithZones(zones ...string) ServerOption {
+	return func(s *Server) {
+		s.zones = append(s.zones, zones...)
+	}
+}
+
+// WithNow sets the function used to determine the current time.
+func WithNow(now func() time.Time) ServerOption {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// NewServer returns a fake Origin CA API with newly generated certificate authorities.
+func NewServer(opts ...ServerOption) (*Server, error) {
+	s := &Server{
+		serviceKeys:  map[string]bool{},
+		tokens:       map[string]bool{},
+		certificates: map[string]*Certificate{},
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var err error
+	s.rsa, err = NewAuthority("RSA", s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.ecc, err = NewAuthority("ECC", s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// RootPEM returns the PEM encoded certificates of the server's RSA and ECDSA
+// certificate authorities.
+func (s *Server) RootPEM() []byte {
+	return append(s.rsa.PEM(), s.ecc.PEM()...)
+}
+
+// Inject queues a fault, which is applied to the next matching requests.
+func (s *Server) Inject(f Fault) {
+	if f.Times <= 0 {
+		f.Times = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// Certificates returns the certificates signed by the server, ordered by ID.
+func (s *Server) Certificates() []Certificate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	certs := make([]Certificate, 0, len(s.certificates))
+	for _, c := range s.certificates {
+		certs = append(certs, *c)
+	}
+
+	sort.Slice(certs, func(i, j int) bool {
+		a, _ := strconv.ParseInt(certs[i].Id, 10, 64)
+		b, _ := strconv.ParseInt(certs[j].Id, 10, 64)
+		return a < b
+	})
+
+	return certs
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("CF-Ray", fmt.Sprintf("%016x-FAKE", s.now().UnixNano()))
+
+	if f := s.fault(r.Method); f != nil {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return
+		}
+
+		if f.Status != 0 {
+			if f.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(f.RetryAfter))
+			}
+
+			writeError(w, f.Status, f.Code, f.Message)
+			return
+		}
+	}
+
+	if !s.authenticated(r) {
+		writeError(w, http.StatusForbidden, ErrorCodeAuthentication, "Authentication error")
+		return
+	}
+
+	if r.URL.Path == "/client/v4/user/tokens/verify" && r.Method == http.MethodGet {
+		s.verifyToken(w, r)
+		return
+	}
+
+	const prefix = "/client/v4/certificates"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.sign(w, r)
+	case id == "" && r.Method == http.MethodGet:
+		s.list(w)
+	case id != "" && r.Method == http.MethodGet:
+		s.get(w, id)
+	case id != "" && r.Method == http.MethodDelete:
+		s.revoke(w, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, fmt.Sprintf("method %s not allowed", r.Method))
+	}
+}
+
+// fault returns the first queued fault matching method, if any.
+func (s *Server) fault(method string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+
+		f.Times--
+		if f.Times <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+// authenticated returns true if the request has an accepted service key or token.
+func (s *Server) authenticated(r *http.Request) bool {
+	if key := r.Header.Get("X-Auth-User-Service-Key"); key != "" {
+		return len(s.serviceKeys) == 0 && len(s.tokens) == 0 || s.serviceKeys[key]
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return len(s.serviceKeys) == 0 && len(s.tokens) == 0 || s.tokens[token]
+	}
+
+	return false
+}
+
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	req := cfapi.SignRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, 0, fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	var ca *Authority
+	switch req.Type {
+	case "origin-rsa":
+		ca = s.rsa
+	case "origin-ecc":
+		ca = s.ecc
+	default:
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidRequestType, fmt.Sprintf("Invalid request type %q", req.Type))
+		return
+	}
+
+	if !validValidity(req.Validity) {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidValidity, fmt.Sprintf("Invalid requested validity %d", req.Validity))
+		return
+	}
+
+	if len(req.Hostnames) == 0 {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidHostnames, "At least one hostname is required")
+		return
+	}
+
+	for _, hostname := range req.Hostnames {
+		if !s.inZones(hostname) {
+			writeError(w, http.StatusBadRequest, ErrorCodeHostnameValidation, fmt.Sprintf("Failed to validate requested hostname %s: This zone is either not part of your account, or you do not have access to it.", hostname))
+			return
+		}
+	}
+
+	block, _ := pem.Decode([]byte(req.CSR))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidCSR, "Failed to parse CSR: no certificate request found")
+		return
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidCSR, fmt.Sprintf("Failed to parse CSR: %s", err))
+		return
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidCSR, fmt.Sprintf("Failed to verify CSR signature: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	s.serial++
+	serial := s.serial
+	s.mu.Unlock()
+
+	now := s.now()
+	expiration := now.Add(time.Duration(req.Validity) * 24 * time.Hour).Truncate(time.Second).UTC()
+
+	der, err := ca.sign(&x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{
+			Organization:       []string{"CloudFlare, Inc."},
+			OrganizationalUnit: []string{"CloudFlare Origin CA"},
+			CommonName:         "CloudFlare Origin Certificate",
+		},
+		DNSNames:    req.Hostnames,
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    expiration,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, csr.PublicKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, 0, fmt.Sprintf("Failed to sign certificate: %s", err))
+		return
+	}
+
+	cert := &Certificate{
+		Id:          strconv.FormatInt(serial, 10),
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Hostnames:   req.Hostnames,
+		Expiration:  expiration.Format(expirationFormat),
+		Type:        req.Type,
+		Validity:    req.Validity,
+		CSR:         req.CSR,
+	}
+
+	s.mu.Lock()
+	s.certificates[cert.Id] = cert
+	s.mu.Unlock()
+
+	writeResult(w, cert)
+}
+
+// verifyToken reports the API Token as active. Service keys cannot be used with
+// the endpoint.
+func (s *Server) verifyToken(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusBadRequest, ErrorCodeAuthentication, "Invalid request headers")
+		return
+	}
+
+	writeResult(w, map[string]string{"id": "fake", "status": "active"})
+}
+
+func (s *Server) list(w http.ResponseWriter) {
+	writeResult(w, s.Certificates())
+}
+
+func (s *Server) get(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cert, ok := s.certificates[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrorCodeNotFound, fmt.Sprintf("Certificate %s not found", id))
+		return
+	}
+
+	writeResult(w, cert)
+}
+
+func (s *Server) revoke(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cert, ok := s.certificates[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrorCodeNotFound, fmt.Sprintf("Certificate %s not found", id))
+		return
+	}
+
+	if cert.RevokedAt == "" {
+		cert.RevokedAt = s.now().UTC().Format(time.RFC3339)
+	}
+
+	writeResult(w, cfapi.RevokeResponse{Id: id})
+}
+
+// inZones returns true if hostname is permitted by the server's zones.
+func (s *Server) inZones(hostname string) bool {
+	if len(s.zones) == 0 {
+		return true
+	}
+
+	hostname = strings.TrimPrefix(strings.ToLower(hostname), "*.")
+	for _, zone := range s.zones {
+		zone = strings.ToLower(zone)
+		if hostname == zone || strings.HasSuffix(hostname, "."+zone) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validValidity(validity int) bool {
+	for _, v := range v1.AllowedValidityDays {
+		if v == validity {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	p, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, 0, err.Error())
+		return
+	}
+
+	writeResponse(w, http.StatusOK, cfapi.APIResponse{
+		Success:  true,
+		Errors:   []cfapi.APIError{},
+		Messages: []string{},
+		Result:   p,
+	})
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	writeResponse(w, status, cfapi.APIResponse{
+		Success:  false,
+		Errors:   []cfapi.APIError{{Code: code, Message: message}},
+		Messages: []string{},
+		Result:   json.RawMessage("null"),
+	})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp cfapi.APIResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,251 @@
+package testingcfapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer_Sign(t *testing.T) {
+	csr := func(t *testing.T) string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("generating key: %s", err)
+		}
+
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: "example.com"},
+			DNSNames: []string{"example.com"},
+		}, key)
+		if err != nil {
+			t.Fatalf("creating CSR: %s", err)
+		}
+
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	}
+
+	tests := []struct {
+		name    string
+		creds   cfapi.Credentials
+		request func(*cfapi.SignRequest)
+		code    int
+	}{
+		{
+			name:  "signs with service key",
+			creds: cfapi.Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")},
+		},
+		{
+			name:  "signs with token",
+			creds: cfapi.Credentials{Token: []byte("api-token")},
+		},
+		{
+			name:  "rejects unknown token",
+			creds: cfapi.Credentials{Token: []byte("bogus")},
+			code:  ErrorCodeAuthentication,
+		},
+		{
+			name:  "rejects invalid validity",
+			creds: cfapi.Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")},
+			request: func(req *cfapi.SignRequest) {
+				req.Validity = 42
+			},
+			code: ErrorCodeInvalidValidity,
+		},
+		{
+			name:  "rejects invalid request type",
+			creds: cfapi.Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")},
+			request: func(req *cfapi.SignRequest) {
+				req.Type = "keyless-certificate"
+			},
+			code: ErrorCodeInvalidRequestType,
+		},
+		{
+			name:  "rejects hostname outside zones",
+			creds: cfapi.Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")},
+			request: func(req *cfapi.SignRequest) {
+				req.Hostnames = []string{"example.net"}
+			},
+			code: ErrorCodeHostnameValidation,
+		},
+		{
+			name:  "rejects invalid csr",
+			creds: cfapi.Credentials{ServiceKey: []byte("v1.0-FFFF-FFFF")},
+			request: func(req *cfapi.SignRequest) {
+				req.CSR = "Lorem ipsum dolor sit amet"
+			},
+			code: ErrorCodeInvalidCSR,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv, err := NewServer(
+				WithServiceKeys("v1.0-FFFF-FFFF"),
+				WithTokens("api-token"),
+				WithZones("example.com"),
+			)
+			if err != nil {
+				t.Fatalf("creating server: %s", err)
+			}
+
+			ts := httptest.NewTLSServer(srv)
+			defer ts.Close()
+
+			endpoint, err := cfapi.WithEndpoint(ts.URL)
+			if err != nil {
+				t.Fatalf("parsing endpoint: %s", err)
+			}
+
+			client, err := cfapi.NewWithCredentials(tt.creds, cfapi.WithClient(ts.Client()), endpoint)
+			if err != nil {
+				t.Fatalf("creating client: %s", err)
+			}
+
+			req := &cfapi.SignRequest{
+				Hostnames: []string{"example.com", "*.example.com"},
+				Validity:  7,
+				Type:      "origin-ecc",
+				CSR:       csr(t),
+			}
+			if tt.request != nil {
+				tt.request(req)
+			}
+
+			resp, err := client.Sign(context.Background(), req)
+			if tt.code != 0 {
+				var apiErr *cfapi.APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected API error, got %v", err)
+				}
+
+				if diff := cmp.Diff(apiErr.Code, tt.code); diff != "" {
+					t.Fatalf("code diff: (-got +want)\n%s", diff)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			block, _ := pem.Decode([]byte(resp.Certificate))
+			if block == nil {
+				t.Fatal("expected PEM encoded certificate")
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("parsing certificate: %s", err)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AppendCertsFromPEM(srv.RootPEM())
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "www.example.com"}); err != nil {
+				t.Fatalf("expected certificate to verify: %s", err)
+			}
+
+			if diff := cmp.Diff(resp.Expiration, cert.NotAfter); diff != "" {
+				t.Fatalf("expiration diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("creating server: %s", err)
+	}
+
+	ts := httptest.NewTLSServer(srv)
+	defer ts.Close()
+
+	endpoint, err := cfapi.WithEndpoint(ts.URL)
+	if err != nil {
+		t.Fatalf("parsing endpoint: %s", err)
+	}
+
+	client := cfapi.New([]byte("v1.0-FFFF-FFFF"), cfapi.WithClient(ts.Client()), endpoint,
+		cfapi.WithRetry(cfapi.RetryOptions{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}),
+	)
+
+	srv.Inject(Fault{Method: http.MethodDelete, Status: http.StatusTooManyRequests, Code: ErrorCodeRateLimited, Message: "rate limited", Times: 2})
+	if _, err := client.Revoke(context.Background(), "1"); cfapi.Classify(err) != cfapi.ErrorCategoryUnknown {
+		t.Fatalf("expected revoke to be retried until not found, got %v", err)
+	}
+
+	srv.Inject(Fault{Status: http.StatusServiceUnavailable, Times: 3})
+	_, err = client.Revoke(context.Background(), "1")
+	if diff := cmp.Diff(cfapi.Classify(err), cfapi.ErrorCategoryUpstreamUnavailable); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestServer_LatencyCanceled(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("creating server: %s", err)
+	}
+
+	srv.Inject(Fault{Latency: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/client/v4/certificates/1", nil).WithContext(ctx))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the delayed response to stop once the request was canceled")
+	}
+}
+
+func TestServer_Revoke(t *testing.T) {
+	now := time.Date(2020, time.December, 25, 6, 27, 0, 0, time.UTC)
+	srv, err := NewServer(WithNow(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("creating server: %s", err)
+	}
+
+	srv.certificates["9001"] = &Certificate{Id: "9001"}
+
+	ts := httptest.NewTLSServer(srv)
+	defer ts.Close()
+
+	endpoint, err := cfapi.WithEndpoint(ts.URL)
+	if err != nil {
+		t.Fatalf("parsing endpoint: %s", err)
+	}
+
+	client := cfapi.NewWithToken([]byte("api-token"), cfapi.WithClient(ts.Client()), endpoint)
+	resp, err := client.Revoke(context.Background(), "9001")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(resp, &cfapi.RevokeResponse{Id: "9001"}); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+
+	if diff := cmp.Diff(srv.Certificates(), []Certificate{{Id: "9001", RevokedAt: "2020-12-25T06:27:00Z"}}); diff != "" {
+		t.Fatalf("certificates diff: (-got +want)\n%s", diff)
+	}
+}
@@ -0,0 +1,144 @@
+package cfapi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// TransportOptions configures the HTTP transport used to reach the Cloudflare API.
+type TransportOptions struct {
+	// ProxyURL is the proxy requests are sent through. If nil, the proxy is
+	// read from the HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL *url.URL
+
+	// CAFile is the path to a PEM file of certificate authorities trusted in
+	// addition to the system roots, such as those of a TLS intercepting proxy.
+	CAFile string
+
+	// ReloadInterval is how often CAFile is checked for changes. If zero, it is
+	// checked before every request.
+	ReloadInterval time.Duration
+
+	// Log reports reloading CAFile.
+	Log logr.Logger
+}
+
+// Transport is an http.RoundTripper that reloads the certificate authorities it
+// trusts when its CAFile changes, so rotated certificates are picked up without
+// restarting the controller.
+type Transport struct {
+	opts TransportOptions
+
+	mu        sync.Mutex
+	transport *http.Transport
+	ca        []byte
+	checked   time.Time
+}
+
+// NewTransport returns a Transport, failing if the CAFile cannot be loaded.
+func NewTransport(opts TransportOptions) (*Transport, error) {
+	t := &Transport{opts: opts}
+
+	var ca []byte
+	if opts.CAFile != "" {
+		var err error
+		if ca, err = os.ReadFile(opts.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	transport, err := t.newTransport(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	t.transport = transport
+	t.ca = ca
+	t.checked = time.Now()
+
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current().RoundTrip(req)
+}
+
+// current returns the transport to send requests with, rebuilding it first if the
+// CAFile has changed since it was last checked. If the changed file cannot be
+// loaded, the previous transport continues to be used.
+func (t *Transport) current() *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.opts.CAFile == "" || time.Since(t.checked) < t.opts.ReloadInterval {
+		return t.transport
+	}
+
+	t.checked = time.Now()
+
+	ca, err := os.ReadFile(t.opts.CAFile)
+	if err != nil {
+		t.opts.Log.Error(err, "failed to read CA file, continuing to use previous certificate authorities", "path", t.opts.CAFile)
+
+		return t.transport
+	}
+
+	if bytes.Equal(ca, t.ca) {
+		return t.transport
+	}
+
+	transport, err := t.newTransport(ca)
+	if err != nil {
+		t.opts.Log.Error(err, "failed to reload CA file, continuing to use previous certificate authorities", "path", t.opts.CAFile)
+
+		return t.transport
+	}
+
+	t.opts.Log.Info("reloaded CA file", "path", t.opts.CAFile)
+
+	t.transport.CloseIdleConnections()
+	t.transport = transport
+	t.ca = ca
+
+	return t.transport
+}
+
+// newTransport returns a transport trusting the system roots, and the certificate
+// authorities in ca if a CAFile is configured.
+func (t *Transport) newTransport(ca []byte) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if t.opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(t.opts.ProxyURL)
+	}
+
+	if t.opts.CAFile == "" {
+		return transport, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", t.opts.CAFile)
+	}
+
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return transport, nil
+}
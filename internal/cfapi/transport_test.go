@@ -0,0 +1,166 @@
+package cfapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTransport_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	otherCA := selfSignedPEM(t)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, otherCA, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := NewTransport(TransportOptions{CAFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	client := &http.Client{Transport: transport}
+
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("expected request to a server signed by an untrusted CA to fail")
+	}
+
+	if err := os.WriteFile(path, serverCA, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected request to succeed once the CA file is reloaded: %s", err)
+	}
+	resp.Body.Close()
+
+	// An invalid CA file keeps the previously loaded certificate authorities.
+	if err := os.WriteFile(path, []byte("bogus"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected request to succeed with the previous CA file: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransport_ReloadInterval(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, selfSignedPEM(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := NewTransport(TransportOptions{CAFile: path, ReloadInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: transport}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("expected the CA file not to be reloaded before the reload interval")
+	}
+}
+
+func TestTransport_InvalidCAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("bogus"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewTransport(TransportOptions{CAFile: path})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if diff := cmp.Diff(err.Error(), "no certificates found in "+path); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestTransport_ProxyURL(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := NewTransport(TransportOptions{ProxyURL: proxyURL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get("http://api.example.com/client/v4/certificates")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if diff := cmp.Diff(proxied, "http://api.example.com/client/v4/certificates"); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+// selfSignedPEM returns a PEM encoded self-signed certificate authority unrelated
+// to any test server.
+func selfSignedPEM(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Unrelated CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
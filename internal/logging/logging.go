@@ -0,0 +1,154 @@
+// Package logging builds the controller's loggers, and scrubs secret material
+// from the messages and values they log.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// FormatJSON logs each message as a JSON object.
+	FormatJSON = "json"
+
+	// FormatConsole logs each message as a human readable line.
+	FormatConsole = "console"
+)
+
+// Levels are the named log levels, mapped to the highest logr verbosity logged at
+// each. Debug messages in this repository are logged at V(4).
+var Levels = map[string]int{
+	"error": -1,
+	"info":  0,
+	"debug": 4,
+}
+
+// maxVerbosity is the highest verbosity representable as a zerolog level.
+const maxVerbosity = 100
+
+// ParseLevel returns the verbosity of a named log level, or of a non-negative
+// integer verbosity.
+func ParseLevel(level string) (int, error) {
+	if v, ok := Levels[level]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(level)
+	if err != nil || v < 0 || v > maxVerbosity {
+		return 0, fmt.Errorf("%q must be one of error, info, debug, or a verbosity between 0 and %d", level, maxVerbosity)
+	}
+
+	return v, nil
+}
+
+// ValidateFormat returns an error if format is not a known log format.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatConsole:
+		return nil
+	default:
+		return fmt.Errorf("%q must be one of %s or %s", format, FormatJSON, FormatConsole)
+	}
+}
+
+// New returns a logger writing messages up to verbosity to w in format, with
+// secret material scrubbed. Errors are always logged.
+//
+// As zerolog also filters messages below its global level, New lowers the global
+// level to fit verbosity if needed.
+func New(w io.Writer, format string, verbosity int) logr.Logger {
+	if format == FormatConsole {
+		w = zerolog.ConsoleWriter{Out: w, NoColor: true}
+	}
+
+	level := zerolog.Level(1 - verbosity)
+	if level < zerolog.GlobalLevel() {
+		zerolog.SetGlobalLevel(level)
+	}
+
+	zl := zerolog.New(w).Level(level).With().Caller().Timestamp().Logger()
+
+	return logr.New(&sink{
+		sink:      zerologr.NewLogSink(&zl),
+		verbosity: verbosity,
+	})
+}
+
+// sink wraps a logr.LogSink, filtering messages above its verbosity and scrubbing
+// secret material from everything it logs.
+type sink struct {
+	sink      logr.LogSink
+	verbosity int
+}
+
+var (
+	_ logr.LogSink          = &sink{}
+	_ logr.CallDepthLogSink = &sink{}
+)
+
+// Init implements logr.LogSink.
+func (s *sink) Init(info logr.RuntimeInfo) {
+	// Account for the frame added by this sink.
+	info.CallDepth++
+	s.sink.Init(info)
+}
+
+// Enabled implements logr.LogSink.
+func (s *sink) Enabled(level int) bool {
+	return level <= s.verbosity
+}
+
+// Info implements logr.LogSink.
+func (s *sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.sink.Info(level, Scrub(msg), scrubValues(keysAndValues)...)
+}
+
+// Error implements logr.LogSink.
+func (s *sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		if scrubbed := Scrub(err.Error()); scrubbed != err.Error() {
+			err = &scrubbedError{msg: scrubbed, err: err}
+		}
+	}
+
+	s.sink.Error(err, Scrub(msg), scrubValues(keysAndValues)...)
+}
+
+// WithValues implements logr.LogSink.
+func (s *sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &sink{sink: s.sink.WithValues(scrubValues(keysAndValues)...), verbosity: s.verbosity}
+}
+
+// WithName implements logr.LogSink.
+func (s *sink) WithName(name string) logr.LogSink {
+	return &sink{sink: s.sink.WithName(name), verbosity: s.verbosity}
+}
+
+// WithCallDepth implements logr.CallDepthLogSink.
+func (s *sink) WithCallDepth(depth int) logr.LogSink {
+	if cd, ok := s.sink.(logr.CallDepthLogSink); ok {
+		return &sink{sink: cd.WithCallDepth(depth), verbosity: s.verbosity}
+	}
+
+	return s
+}
+
+// scrubbedError replaces the message of an error that contained secret material,
+// while keeping the original error for errors.Is and errors.As.
+type scrubbedError struct {
+	msg string
+	err error
+}
+
+func (e *scrubbedError) Error() string {
+	return e.msg
+}
+
+func (e *scrubbedError) Unwrap() error {
+	return e.err
+}
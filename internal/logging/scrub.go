@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secret material in logged messages and values.
+const redacted = "[REDACTED]"
+
+var (
+	// pemBlock matches PEM encoded blocks, such as CSRs and private keys.
+	pemBlock = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]+)-----[\s\S]*?(?:-----END [A-Z0-9 ]+-----|$)`)
+
+	// serviceKey matches Origin CA service keys.
+	serviceKey = regexp.MustCompile(`v1\.0-[0-9A-Za-z-]{16,}`)
+
+	// bearer matches API tokens sent in an Authorization header.
+	bearer = regexp.MustCompile(`(?i)(bearer\s+)[0-9A-Za-z_.-]+`)
+)
+
+// sensitiveKeys are logged keys, compared case-insensitively with dashes and
+// underscores removed, whose values are always redacted.
+var sensitiveKeys = map[string]bool{
+	"servicekey":    true,
+	"token":         true,
+	"apitoken":      true,
+	"privatekey":    true,
+	"csr":           true,
+	"password":      true,
+	"authorization": true,
+}
+
+// Scrub returns s with Origin CA service keys, bearer tokens, and PEM blocks
+// other than certificates redacted.
+func Scrub(s string) string {
+	s = pemBlock.ReplaceAllStringFunc(s, func(block string) string {
+		if strings.HasPrefix(block, "-----BEGIN CERTIFICATE-----") && strings.HasSuffix(block, "-----END CERTIFICATE-----") {
+			return block
+		}
+
+		return redacted
+	})
+	s = serviceKey.ReplaceAllString(s, redacted)
+	s = bearer.ReplaceAllString(s, "${1}"+redacted)
+
+	return s
+}
+
+// scrubValues returns a copy of keysAndValues with the values of sensitive keys
+// redacted, and secret material scrubbed from string values.
+func scrubValues(keysAndValues []interface{}) []interface{} {
+	if len(keysAndValues) == 0 {
+		return keysAndValues
+	}
+
+	scrubbed := make([]interface{}, len(keysAndValues))
+	copy(scrubbed, keysAndValues)
+
+	for i := 1; i < len(scrubbed); i += 2 {
+		if key, ok := scrubbed[i-1].(string); ok && isSensitive(key) {
+			scrubbed[i] = redacted
+			continue
+		}
+
+		switch v := scrubbed[i].(type) {
+		case string:
+			scrubbed[i] = Scrub(v)
+		case []byte:
+			scrubbed[i] = Scrub(string(v))
+		case error:
+			if s := Scrub(v.Error()); s != v.Error() {
+				scrubbed[i] = s
+			}
+		case fmt.Stringer:
+			if s := Scrub(v.String()); s != v.String() {
+				scrubbed[i] = s
+			}
+		}
+	}
+
+	return scrubbed
+}
+
+func isSensitive(key string) bool {
+	key = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+
+	return sensitiveKeys[key]
+}
@@ -0,0 +1,67 @@
+// Package metrics defines the Prometheus metrics of the origin-ca-issuer,
+// registered with the controller-runtime metrics registry so they are
+// served from the manager's metrics endpoint.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "origin_ca_issuer"
+
+var (
+	// APIRequestDuration observes the latency of each attempt at a Cloudflare
+	// API request, by method, HTTP status, and API error code. The status is
+	// "error" if no response was received.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of Cloudflare API requests, by method, HTTP status, and API error code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "status", "code"})
+
+	// SignTotal counts the CertificateRequests signed, by issuer, request type,
+	// and outcome. The outcome is "Issued", or the category of the error.
+	SignTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sign_total",
+		Help:      "Number of attempts to sign CertificateRequests, by issuer, request type, and outcome.",
+	}, []string{"issuer_kind", "issuer", "request_type", "outcome"})
+
+	// ValidityDays observes the validity, in days, of certificates signed by the
+	// Cloudflare API and verified, by request type.
+	ValidityDays = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "certificate_validity_days",
+		Help:      "Validity, in days, of issued certificates.",
+		Buckets:   []float64{7, 30, 90, 365, 730, 1095, 5475},
+	}, []string{"request_type"})
+
+	// Provisioners is the number of provisioners cached for ready issuers.
+	Provisioners = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provisioners",
+		Help:      "Number of provisioners cached for ready issuers.",
+	})
+
+	// IssuanceDuration observes the time from the creation of a
+	// CertificateRequest until its certificate is issued.
+	IssuanceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "certificate_request_issuance_duration_seconds",
+		Help:      "Time from the creation of a CertificateRequest until its certificate is issued.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"issuer_kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		APIRequestDuration,
+		SignTotal,
+		ValidityDays,
+		Provisioners,
+		IssuanceDuration,
+	)
+}
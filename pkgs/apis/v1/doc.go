@@ -24,4 +24,5 @@ var (
 
 func init() {
 	SchemeBuilder.Register(&OriginIssuer{}, &OriginIssuerList{})
+	SchemeBuilder.Register(&ClusterOriginIssuer{}, &ClusterOriginIssuerList{})
 }
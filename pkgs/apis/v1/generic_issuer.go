@@ -0,0 +1,40 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// GenericIssuer is implemented by both OriginIssuer and ClusterOriginIssuer,
+// allowing controllers to operate on either kind.
+// +kubebuilder:object:generate=false
+type GenericIssuer interface {
+	runtime.Object
+	metav1.Object
+
+	GetSpec() *OriginIssuerSpec
+	GetStatus() *OriginIssuerStatus
+}
+
+var _ GenericIssuer = &OriginIssuer{}
+var _ GenericIssuer = &ClusterOriginIssuer{}
+
+// GetSpec returns the OriginIssuer's spec.
+func (i *OriginIssuer) GetSpec() *OriginIssuerSpec {
+	return &i.Spec
+}
+
+// GetStatus returns the OriginIssuer's status.
+func (i *OriginIssuer) GetStatus() *OriginIssuerStatus {
+	return &i.Status
+}
+
+// GetSpec returns the ClusterOriginIssuer's spec.
+func (i *ClusterOriginIssuer) GetSpec() *OriginIssuerSpec {
+	return &i.Spec
+}
+
+// GetStatus returns the ClusterOriginIssuer's status.
+func (i *ClusterOriginIssuer) GetStatus() *OriginIssuerStatus {
+	return &i.Status
+}
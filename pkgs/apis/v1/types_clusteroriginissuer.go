@@ -0,0 +1,35 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// A ClusterOriginIssuer represents the Cloudflare Origin CA as an external cert-manager issuer.
+// It is scoped to the cluster, so it can be used by resources in any namespace. Secrets
+// referenced by a ClusterOriginIssuer are read from the controller's cluster resource
+// namespace.
+type ClusterOriginIssuer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Desired state of the ClusterOriginIssuer resource
+	Spec OriginIssuerSpec `json:"spec,omitempty"`
+
+	// Status of the ClusterOriginIssuer. This is set and managed automatically.
+	// +optional
+	Status OriginIssuerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterOriginIssuerList is a list of ClusterOriginIssuers.
+type ClusterOriginIssuerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata.omitempty"`
+
+	Items []ClusterOriginIssuer `json:"items"`
+}
@@ -36,10 +36,98 @@ type OriginIssuerList struct {
 // configuration required for the issuer.
 type OriginIssuerSpec struct {
 	// RequestType is the signature algorithm Cloudflare should use to sign the certificate.
+	// `Auto` selects the signature algorithm matching the key of each CSR.
 	RequestType RequestType `json:"requestType"`
 
 	// Auth configures how to authenticate with the Cloudflare API.
 	Auth OriginIssuerAuthentication `json:"auth"`
+
+	// RevocationPolicy controls when certificates signed by this issuer are
+	// revoked with the Cloudflare API. Defaults to `Never`.
+	// +optional
+	RevocationPolicy RevocationPolicy `json:"revocationPolicy,omitempty"`
+
+	// Policy restricts the hostnames certificates may be requested for.
+	// CertificateRequests violating the policy are denied without calling
+	// the Cloudflare API.
+	// +optional
+	Policy *OriginIssuerPolicy `json:"policy,omitempty"`
+
+	// ValidityPolicy controls how the requested duration of a certificate is
+	// normalized to a validity allowed by the Cloudflare API. Defaults to `Nearest`.
+	// +optional
+	ValidityPolicy ValidityPolicy `json:"validityPolicy,omitempty"`
+
+	// MinValidityDays is the shortest validity, in days, certificates are
+	// requested with. Shorter durations are raised to the nearest allowed
+	// validity of at least this many days, or rejected by the `Strict` policy.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinValidityDays int `json:"minValidityDays,omitempty"`
+
+	// MaxValidityDays is the longest validity, in days, certificates are
+	// requested with. Longer durations are lowered to the nearest allowed
+	// validity of at most this many days, or rejected by the `Strict` policy.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxValidityDays int `json:"maxValidityDays,omitempty"`
+
+	// CABundle is a PEM encoded bundle of Origin CA root certificates, overriding
+	// the roots configured for the controller. Certificates signed by this issuer
+	// must chain to one of them, and the root they chain to is set as the CA of
+	// their CertificateRequest. Only one of `caBundle` or `caBundleRef` may be
+	// specified.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// CABundleRef references a ConfigMap key holding a PEM encoded bundle of
+	// Origin CA root certificates, used as `caBundle`.
+	// +optional
+	CABundleRef *ConfigMapKeySelector `json:"caBundleRef,omitempty"`
+
+	// Endpoint overrides the base URL of the Cloudflare API requests for this
+	// issuer are sent to, such as a mock API for staging. Only the scheme and
+	// host are used. The controller must be started with
+	// `--allow-issuer-endpoints` for the override to be accepted.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// RateLimit limits how often certificates are signed by this issuer,
+	// overriding the limit configured for the controller. CertificateRequests
+	// waiting for the rate limit remain `Pending`.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+}
+
+// RateLimit is a token bucket limiting how often an issuer signs certificates with
+// the Cloudflare API. The bucket holds up to Burst requests, and is refilled at
+// RequestsPerMinute.
+type RateLimit struct {
+	// RequestsPerMinute is the sustained rate certificates are signed at.
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerMinute int `json:"requestsPerMinute"`
+
+	// Burst is the number of certificates that may be signed at once before
+	// being limited to the sustained rate. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int `json:"burst,omitempty"`
+}
+
+// OriginIssuerPolicy restricts the hostnames an issuer will sign certificates for.
+// Patterns are either an exact hostname, such as `example.com`, or a wildcard
+// suffix, such as `*.example.com`, matching any subdomain of `example.com` at
+// any depth, including wildcard hostnames. Matching is case-insensitive.
+type OriginIssuerPolicy struct {
+	// AllowedDomains lists the patterns every requested hostname must match.
+	// If empty, all hostnames not denied are allowed.
+	// +optional
+	AllowedDomains []string `json:"allowedDomains,omitempty"`
+
+	// DeniedDomains lists the patterns no requested hostname may match. Denied
+	// patterns take precedence over allowed patterns.
+	// +optional
+	DeniedDomains []string `json:"deniedDomains,omitempty"`
 }
 
 // OriginIssuerStatus contains status information about an OriginIssuer
@@ -51,21 +139,35 @@ type OriginIssuerStatus struct {
 }
 
 // OriginIssuerAuthentication defines how to authenticate with the Cloudflare API.
-// Only one of `serviceKeyRef` may be specified.
+// Exactly one of `serviceKeyRef` or `tokenRef` must be specified.
 type OriginIssuerAuthentication struct {
 	// ServiceKeyRef authenticates with an API Service Key.
 	// +optional
 	ServiceKeyRef SecretKeySelector `json:"serviceKeyRef,omitempty"`
+
+	// TokenRef authenticates with an API Token.
+	// +optional
+	TokenRef SecretKeySelector `json:"tokenRef,omitempty"`
 }
 
 // SecretKeySelector contains a reference to a secret.
 type SecretKeySelector struct {
-	// Name of the secret in the OriginIssuer's namespace to select from.
+	// Name of the secret in the OriginIssuer's namespace to select from. Secrets
+	// referenced by a ClusterOriginIssuer are read from the cluster resource namespace.
 	Name string `json:"name"`
 	// Key of the secret to select from. Must be a valid secret key.
 	Key string `json:"key"`
 }
 
+// ConfigMapKeySelector contains a reference to a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap in the OriginIssuer's namespace to select from. ConfigMaps
+	// referenced by a ClusterOriginIssuer are read from the cluster resource namespace.
+	Name string `json:"name"`
+	// Key of the ConfigMap to select from. Must be a valid ConfigMap key.
+	Key string `json:"key"`
+}
+
 // OriginIssuerCondition contains condition information for the OriginIssuer.
 type OriginIssuerCondition struct {
 	// Type of the condition, known values are ('Ready')
@@ -90,7 +192,7 @@ type OriginIssuerCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
-// +kubebuilder:validation:Enum=OriginRSA;OriginECC
+// +kubebuilder:validation:Enum=OriginRSA;OriginECC;Auto
 
 // RequestType represents the signature algorithm used to sign certificates.
 type RequestType string
@@ -101,6 +203,54 @@ const (
 
 	// RequestTypeOriginECC represents an ECDSA signature.
 	RequestTypeOriginECC RequestType = "OriginECC"
+
+	// RequestTypeAuto represents an RSA256 signature for CSRs with an RSA key,
+	// and an ECDSA signature for CSRs with an ECDSA key.
+	RequestTypeAuto RequestType = "Auto"
+)
+
+// +kubebuilder:validation:Enum=Never;OnRenewal;OnDelete
+
+// RevocationPolicy represents when issued certificates are revoked.
+type RevocationPolicy string
+
+const (
+	// RevocationPolicyNever never revokes certificates; they remain valid
+	// until they expire.
+	RevocationPolicyNever RevocationPolicy = "Never"
+
+	// RevocationPolicyOnRenewal revokes a certificate once its Certificate
+	// has been renewed and the certificate has been superseded.
+	RevocationPolicyOnRenewal RevocationPolicy = "OnRenewal"
+
+	// RevocationPolicyOnDelete revokes certificates when superseded, as with
+	// OnRenewal, and additionally revokes the current certificate when its
+	// Certificate is deleted.
+	RevocationPolicyOnDelete RevocationPolicy = "OnDelete"
+)
+
+// +kubebuilder:validation:Enum=Nearest;RoundUp;RoundDown;Strict
+
+// ValidityPolicy represents how requested durations are normalized to a
+// validity allowed by the Cloudflare API.
+type ValidityPolicy string
+
+const (
+	// ValidityPolicyNearest requests the allowed validity closest to the
+	// requested duration.
+	ValidityPolicyNearest ValidityPolicy = "Nearest"
+
+	// ValidityPolicyRoundUp requests the shortest allowed validity no shorter
+	// than the requested duration.
+	ValidityPolicyRoundUp ValidityPolicy = "RoundUp"
+
+	// ValidityPolicyRoundDown requests the longest allowed validity no longer
+	// than the requested duration.
+	ValidityPolicyRoundDown ValidityPolicy = "RoundDown"
+
+	// ValidityPolicyStrict rejects requested durations that are not exactly
+	// an allowed validity.
+	ValidityPolicyStrict ValidityPolicy = "Strict"
 )
 
 // +kubebuilder:validation:Enum=Ready
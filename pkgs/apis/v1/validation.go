@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Validate ensures required fields are set, and enums are correctly set. All
+// problems with the spec are reported, joined in the returned error.
+func (s *OriginIssuerSpec) Validate() error {
+	var errs []error
+
+	hasServiceKey := s.Auth.ServiceKeyRef != (SecretKeySelector{})
+	hasToken := s.Auth.TokenRef != (SecretKeySelector{})
+
+	switch {
+	case hasServiceKey && hasToken:
+		errs = append(errs, fmt.Errorf("only one of spec.auth.serviceKeyRef or spec.auth.tokenRef may be specified"))
+	case !hasServiceKey && !hasToken:
+		errs = append(errs, fmt.Errorf("one of spec.auth.serviceKeyRef or spec.auth.tokenRef must be specified"))
+	}
+
+	if hasServiceKey {
+		errs = append(errs, validateSecretKeySelector("spec.auth.serviceKeyRef", s.Auth.ServiceKeyRef)...)
+	}
+
+	if hasToken {
+		errs = append(errs, validateSecretKeySelector("spec.auth.tokenRef", s.Auth.TokenRef)...)
+	}
+
+	switch s.RequestType {
+	case RequestTypeOriginRSA, RequestTypeOriginECC, RequestTypeAuto:
+	case "":
+		errs = append(errs, fmt.Errorf("spec.requestType cannot be empty"))
+	default:
+		errs = append(errs, fmt.Errorf("spec.requestType has invalid value %q", s.RequestType))
+	}
+
+	switch s.RevocationPolicy {
+	case "", RevocationPolicyNever, RevocationPolicyOnRenewal, RevocationPolicyOnDelete:
+	default:
+		errs = append(errs, fmt.Errorf("spec.revocationPolicy has invalid value %q", s.RevocationPolicy))
+	}
+
+	switch s.ValidityPolicy {
+	case "", ValidityPolicyNearest, ValidityPolicyRoundUp, ValidityPolicyRoundDown, ValidityPolicyStrict:
+	default:
+		errs = append(errs, fmt.Errorf("spec.validityPolicy has invalid value %q", s.ValidityPolicy))
+	}
+
+	errs = append(errs, s.validateValidityBounds()...)
+
+	if s.Policy != nil {
+		for i, pattern := range s.Policy.AllowedDomains {
+			errs = append(errs, validateDomainPattern(fmt.Sprintf("spec.policy.allowedDomains[%d]", i), pattern)...)
+		}
+
+		for i, pattern := range s.Policy.DeniedDomains {
+			errs = append(errs, validateDomainPattern(fmt.Sprintf("spec.policy.deniedDomains[%d]", i), pattern)...)
+		}
+	}
+
+	switch {
+	case len(s.CABundle) > 0 && s.CABundleRef != nil:
+		errs = append(errs, fmt.Errorf("only one of spec.caBundle or spec.caBundleRef may be specified"))
+	case len(s.CABundle) > 0:
+		if !x509.NewCertPool().AppendCertsFromPEM(s.CABundle) {
+			errs = append(errs, fmt.Errorf("spec.caBundle does not contain any PEM encoded certificates"))
+		}
+	case s.CABundleRef != nil:
+		errs = append(errs, validateKeySelector("spec.caBundleRef", s.CABundleRef.Name, s.CABundleRef.Key)...)
+	}
+
+	if s.Endpoint != "" {
+		errs = append(errs, validateEndpoint("spec.endpoint", s.Endpoint)...)
+	}
+
+	if s.RateLimit != nil {
+		errs = append(errs, validateRateLimit("spec.rateLimit", s.RateLimit)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateEndpoint ensures the endpoint is an absolute HTTP or HTTPS URL.
+func validateEndpoint(path, endpoint string) []error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return []error{fmt.Errorf("%s has invalid value %q: %w", path, endpoint, err)}
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return []error{fmt.Errorf("%s has invalid value %q: scheme must be https or http", path, endpoint)}
+	}
+
+	if u.Host == "" {
+		return []error{fmt.Errorf("%s has invalid value %q: host cannot be empty", path, endpoint)}
+	}
+
+	return nil
+}
+
+// validateRateLimit ensures the rate limit allows at least one request per minute,
+// and the burst is not negative.
+func validateRateLimit(path string, limit *RateLimit) []error {
+	var errs []error
+
+	if limit.RequestsPerMinute < 1 {
+		errs = append(errs, fmt.Errorf("%s.requestsPerMinute has invalid value %d: must be at least 1", path, limit.RequestsPerMinute))
+	}
+
+	if limit.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%s.burst has invalid value %d: must not be negative", path, limit.Burst))
+	}
+
+	return errs
+}
+
+// validateValidityBounds ensures the minimum and maximum validity are not negative,
+// and allow at least one validity accepted by the Cloudflare API.
+func (s *OriginIssuerSpec) validateValidityBounds() []error {
+	var errs []error
+
+	if s.MinValidityDays < 0 {
+		errs = append(errs, fmt.Errorf("spec.minValidityDays has invalid value %d: must not be negative", s.MinValidityDays))
+	}
+
+	if s.MaxValidityDays < 0 {
+		errs = append(errs, fmt.Errorf("spec.maxValidityDays has invalid value %d: must not be negative", s.MaxValidityDays))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, days := range AllowedValidityDays {
+		if days >= s.MinValidityDays && (s.MaxValidityDays == 0 || days <= s.MaxValidityDays) {
+			return nil
+		}
+	}
+
+	return []error{fmt.Errorf("spec.minValidityDays and spec.maxValidityDays must allow at least one of the validities %v (in days)", AllowedValidityDays)}
+}
+
+// validateDomainPattern ensures the pattern is a hostname, optionally prefixed
+// with a `*.` wildcard label.
+func validateDomainPattern(path, pattern string) []error {
+	if pattern == "" {
+		return []error{fmt.Errorf("%s cannot be empty", path)}
+	}
+
+	domain := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(pattern), "."), "*.")
+	if strings.Contains(domain, "*") {
+		return []error{fmt.Errorf("%s has invalid value %q: a wildcard is only allowed as the leftmost label", path, pattern)}
+	}
+
+	if msgs := validation.IsDNS1123Subdomain(domain); len(msgs) > 0 {
+		return []error{fmt.Errorf("%s has invalid value %q: %s", path, pattern, strings.Join(msgs, ", "))}
+	}
+
+	return nil
+}
+
+// validateSecretKeySelector ensures the selector names a valid Secret and key.
+func validateSecretKeySelector(path string, ref SecretKeySelector) []error {
+	return validateKeySelector(path, ref.Name, ref.Key)
+}
+
+// validateKeySelector ensures name is a valid Secret or ConfigMap name, and key
+// a valid key.
+func validateKeySelector(path, name, key string) []error {
+	var errs []error
+
+	if name == "" {
+		errs = append(errs, fmt.Errorf("%s.name cannot be empty", path))
+	} else if msgs := validation.IsDNS1123Subdomain(name); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("%s.name has invalid value %q: %s", path, name, strings.Join(msgs, ", ")))
+	}
+
+	if key == "" {
+		errs = append(errs, fmt.Errorf("%s.key cannot be empty", path))
+	} else if msgs := validation.IsConfigMapKey(key); len(msgs) > 0 {
+		errs = append(errs, fmt.Errorf("%s.key has invalid value %q: %s", path, key, strings.Join(msgs, ", ")))
+	}
+
+	return errs
+}
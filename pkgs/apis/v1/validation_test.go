@@ -0,0 +1,295 @@
+package v1
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOriginIssuerSpec_Validate(t *testing.T) {
+	serviceKey := SecretKeySelector{Name: "service-key", Key: "key"}
+	token := SecretKeySelector{Name: "api-token", Key: "token"}
+
+	ca, err := selfSignedPEM()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		spec  OriginIssuerSpec
+		error string
+	}{
+		{
+			name: "service key",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+			},
+		},
+		{
+			name: "api token",
+			spec: OriginIssuerSpec{
+				RequestType:      RequestTypeOriginECC,
+				Auth:             OriginIssuerAuthentication{TokenRef: token},
+				RevocationPolicy: RevocationPolicyOnDelete,
+			},
+		},
+		{
+			name: "both auth methods",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey, TokenRef: token},
+			},
+			error: "only one of spec.auth.serviceKeyRef or spec.auth.tokenRef may be specified",
+		},
+		{
+			name: "no auth methods",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+			},
+			error: "one of spec.auth.serviceKeyRef or spec.auth.tokenRef must be specified",
+		},
+		{
+			name: "token missing key",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{TokenRef: SecretKeySelector{Name: "api-token"}},
+			},
+			error: "spec.auth.tokenRef.key cannot be empty",
+		},
+		{
+			name: "invalid secret name",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: SecretKeySelector{Name: "Service_Key", Key: "key"}},
+			},
+			error: `spec.auth.serviceKeyRef.name has invalid value "Service_Key": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name: "invalid request type",
+			spec: OriginIssuerSpec{
+				RequestType: "OriginDSA",
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+			},
+			error: `spec.requestType has invalid value "OriginDSA"`,
+		},
+		{
+			name: "invalid revocation policy",
+			spec: OriginIssuerSpec{
+				RequestType:      RequestTypeOriginRSA,
+				Auth:             OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				RevocationPolicy: "Sometimes",
+			},
+			error: `spec.revocationPolicy has invalid value "Sometimes"`,
+		},
+		{
+			name: "domain policy",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				Policy: &OriginIssuerPolicy{
+					AllowedDomains: []string{"example.com", "*.example.com"},
+					DeniedDomains:  []string{"Admin.Example.com."},
+				},
+			},
+		},
+		{
+			name: "invalid domain policy",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				Policy: &OriginIssuerPolicy{
+					AllowedDomains: []string{"", "www.*.example.com"},
+					DeniedDomains:  []string{"exa_mple.com"},
+				},
+			},
+			error: `spec.policy.allowedDomains[0] cannot be empty
+spec.policy.allowedDomains[1] has invalid value "www.*.example.com": a wildcard is only allowed as the leftmost label
+spec.policy.deniedDomains[0] has invalid value "exa_mple.com": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name: "validity policy",
+			spec: OriginIssuerSpec{
+				RequestType:     RequestTypeOriginECC,
+				Auth:            OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				ValidityPolicy:  ValidityPolicyRoundUp,
+				MinValidityDays: 30,
+				MaxValidityDays: 365,
+			},
+		},
+		{
+			name: "invalid validity policy",
+			spec: OriginIssuerSpec{
+				RequestType:    RequestTypeOriginECC,
+				Auth:           OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				ValidityPolicy: "Closest",
+			},
+			error: `spec.validityPolicy has invalid value "Closest"`,
+		},
+		{
+			name: "negative validity bounds",
+			spec: OriginIssuerSpec{
+				RequestType:     RequestTypeOriginECC,
+				Auth:            OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				MinValidityDays: -1,
+			},
+			error: "spec.minValidityDays has invalid value -1: must not be negative",
+		},
+		{
+			name: "validity bounds exclude every allowed validity",
+			spec: OriginIssuerSpec{
+				RequestType:     RequestTypeOriginECC,
+				Auth:            OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				MinValidityDays: 100,
+				MaxValidityDays: 300,
+			},
+			error: "spec.minValidityDays and spec.maxValidityDays must allow at least one of the validities [7 30 90 365 730 1095 5475] (in days)",
+		},
+		{
+			name: "ca bundle",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				CABundle:    ca,
+			},
+		},
+		{
+			name: "ca bundle reference",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				CABundleRef: &ConfigMapKeySelector{Name: "origin-ca-roots", Key: "ca.crt"},
+			},
+		},
+		{
+			name: "invalid ca bundle",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				CABundle:    []byte("bogus"),
+			},
+			error: "spec.caBundle does not contain any PEM encoded certificates",
+		},
+		{
+			name: "invalid ca bundle reference",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				CABundleRef: &ConfigMapKeySelector{Name: "origin-ca-roots"},
+			},
+			error: "spec.caBundleRef.key cannot be empty",
+		},
+		{
+			name: "endpoint",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				Endpoint:    "https://api.staging.example.com",
+			},
+		},
+		{
+			name: "endpoint without scheme",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				Endpoint:    "api.staging.example.com",
+			},
+			error: `spec.endpoint has invalid value "api.staging.example.com": scheme must be https or http`,
+		},
+		{
+			name: "endpoint without host",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				Endpoint:    "https:///client/v4",
+			},
+			error: `spec.endpoint has invalid value "https:///client/v4": host cannot be empty`,
+		},
+		{
+			name: "rate limit",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				RateLimit:   &RateLimit{RequestsPerMinute: 60, Burst: 10},
+			},
+		},
+		{
+			name: "invalid rate limit",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				RateLimit:   &RateLimit{RequestsPerMinute: 0, Burst: -1},
+			},
+			error: "spec.rateLimit.requestsPerMinute has invalid value 0: must be at least 1\nspec.rateLimit.burst has invalid value -1: must not be negative",
+		},
+		{
+			name: "ca bundle and reference",
+			spec: OriginIssuerSpec{
+				RequestType: RequestTypeOriginECC,
+				Auth:        OriginIssuerAuthentication{ServiceKeyRef: serviceKey},
+				CABundle:    ca,
+				CABundleRef: &ConfigMapKeySelector{Name: "origin-ca-roots", Key: "ca.crt"},
+			},
+			error: "only one of spec.caBundle or spec.caBundleRef may be specified",
+		},
+		{
+			name:  "reports every problem",
+			spec:  OriginIssuerSpec{},
+			error: "one of spec.auth.serviceKeyRef or spec.auth.tokenRef must be specified\nspec.requestType cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.error == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+				t.Fatalf("diff: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+// selfSignedPEM returns a PEM encoded self-signed certificate authority.
+func selfSignedPEM() ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Origin CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
@@ -0,0 +1,28 @@
+package v1
+
+// AllowedValidityDays are the validities, in days, certificates may be
+// requested with from the Cloudflare API, in ascending order.
+var AllowedValidityDays = []int{7, 30, 90, 365, 730, 1095, 5475}
+
+const (
+	// CertificateIDAnnotationKey is the annotation set on a CertificateRequest
+	// recording the ID of the certificate issued by the Cloudflare API.
+	CertificateIDAnnotationKey = "cert-manager.k8s.cloudflare.com/certificate-id"
+
+	// CertificateExpirationAnnotationKey is the annotation set on a CertificateRequest
+	// recording the expiration of the issued certificate, formatted as RFC 3339.
+	CertificateExpirationAnnotationKey = "cert-manager.k8s.cloudflare.com/certificate-expiration"
+
+	// CertificateValidityAnnotationKey is the annotation set on a CertificateRequest
+	// recording the validity, in days, the certificate was requested with after
+	// normalizing to a validity allowed by the Cloudflare API.
+	CertificateValidityAnnotationKey = "cert-manager.k8s.cloudflare.com/certificate-validity"
+
+	// RevokedAtAnnotationKey is the annotation set on a CertificateRequest
+	// once its certificate has been revoked with the Cloudflare API.
+	RevokedAtAnnotationKey = "cert-manager.k8s.cloudflare.com/revoked-at"
+
+	// RevocationFinalizer is added to Certificates whose issuer revokes
+	// certificates on deletion.
+	RevocationFinalizer = "cert-manager.k8s.cloudflare.com/revocation"
+)
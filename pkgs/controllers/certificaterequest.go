@@ -2,16 +2,23 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	"github.com/cloudflare/origin-ca-issuer/internal/metrics"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
 	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -22,20 +29,31 @@ import (
 type CertificateRequestController struct {
 	client.Client
 	Log        logr.Logger
+	Recorder   record.EventRecorder
 	Collection *provisioners.Collection
 
 	Clock                  clock.Clock
 	CheckApprovedCondition bool
+
+	// Scope restricts the CertificateRequests reconciled, and the issuers they
+	// may reference.
+	Scope Scope
 }
 
-// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests/status,verbs=get;update;patch
 
 // Reconcile reconciles CertificateRequest by fetching a Cloudflare API provisioner from
-// the referenced OriginIssuer, and providing the request's CSR.
+// the referenced OriginIssuer or ClusterOriginIssuer, and providing the request's CSR.
 func (r *CertificateRequestController) Reconcile(ctx context.Context, cr *certmanager.CertificateRequest) (reconcile.Result, error) {
 	log := r.Log.WithValues("namespace", cr.Namespace, "certificaterequest", cr.Name)
 
+	if !r.Scope.Contains(cr) {
+		log.V(4).Info("resource is not within the controller's scope")
+
+		return reconcile.Result{}, nil
+	}
+
 	if cr.Spec.IssuerRef.Group != "" && cr.Spec.IssuerRef.Group != v1.GroupVersion.Group {
 		log.V(4).Info("resource does not specify an issuerRef group name that we are responsible for", "group", cr.Spec.IssuerRef.Group)
 
@@ -80,6 +98,8 @@ func (r *CertificateRequestController) Reconcile(ctx context.Context, cr *certma
 		}
 
 		message := "The CertificateRequest was denied by an approval controller"
+		r.Recorder.Event(cr, core.EventTypeWarning, certmanager.CertificateRequestReasonDenied, message)
+
 		return reconcile.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonDenied, message)
 	}
 
@@ -97,29 +117,34 @@ func (r *CertificateRequestController) Reconcile(ctx context.Context, cr *certma
 		return reconcile.Result{}, nil
 	}
 
-	if cr.Spec.IsCA {
-		log.Info("Origin Issuer does not support signing of CA certificates")
+	iss, issNamespaceName, kind := r.Scope.issuerFor(cr.Spec.IssuerRef, cr.Namespace)
+	if kind == "" {
+		log.V(4).Info("resource does not specify an issuerRef kind that we are responsible for", "kind", cr.Spec.IssuerRef.Kind)
 
 		return reconcile.Result{}, nil
 	}
 
-	iss := v1.OriginIssuer{}
-	issNamespaceName := types.NamespacedName{
-		Namespace: cr.Namespace,
-		Name:      cr.Spec.IssuerRef.Name,
+	// Requests the Cloudflare API cannot honor are failed, rather than left
+	// pending until cert-manager gives up on them, even if the issuer is missing
+	// or not ready. The request type is resolved from the CSR's key alone, as
+	// the issuer has not been retrieved.
+	if err := checkSupported(cr); err != nil {
+		log.Info("certificate request is not supported by Origin CA", "reason", err.Error())
+
+		return reconcile.Result{}, r.deny(ctx, cr, kind, issNamespaceName, provisioners.ResolveRequestType(v1.RequestTypeAuto, cr), "Unsupported", fmt.Sprintf("Failed to sign certificate request for %s %s: %v", kind, issNamespaceName, err))
 	}
 
-	if err := r.Client.Get(ctx, issNamespaceName, &iss); err != nil {
-		log.Error(err, "failed to retrieve OriginIssuer resource", "namespace", issNamespaceName.Namespace, "name", issNamespaceName.Name)
-		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("Failed to retrieve OriginIssuer resource %s: %v", issNamespaceName, err))
+	if err := r.Client.Get(ctx, issNamespaceName, iss); err != nil {
+		log.Error(err, "failed to retrieve issuer resource", "kind", kind, "namespace", issNamespaceName.Namespace, "name", issNamespaceName.Name)
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("Failed to retrieve %s resource %s: %v", kind, issNamespaceName, err))
 
 		return reconcile.Result{}, err
 	}
 
 	if !IssuerHasCondition(iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue}) {
 		err := fmt.Errorf("resource %s is not ready", issNamespaceName)
-		log.Error(err, "issuer failed readiness checks", "namespace", issNamespaceName.Namespace, "name", issNamespaceName.Name)
-		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("OriginIssuer %s is not Ready", issNamespaceName))
+		log.Error(err, "issuer failed readiness checks", "kind", kind, "namespace", issNamespaceName.Namespace, "name", issNamespaceName.Name)
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("%s %s is not Ready", kind, issNamespaceName))
 
 		return reconcile.Result{}, err
 	}
@@ -127,27 +152,166 @@ func (r *CertificateRequestController) Reconcile(ctx context.Context, cr *certma
 	p, ok := r.Collection.Load(issNamespaceName)
 	if !ok {
 		err := fmt.Errorf("provisioner %s not found", issNamespaceName)
-		log.Error(err, "failed to load provisioner for OriginIssuer resource")
+		log.Error(err, "failed to load provisioner for issuer resource", "kind", kind)
 
-		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("Failed to load provisioner for OriginIssuer resource %s", issNamespaceName))
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("Failed to load provisioner for %s resource %s", kind, issNamespaceName))
 
 		return reconcile.Result{}, err
 	}
 
-	pem, err := p.Sign(ctx, cr)
+	reqType := provisioners.ResolveRequestType(iss.GetSpec().RequestType, cr)
+
+	res, err := p.Sign(ctx, cr)
+	var (
+		policyErr   *provisioners.PolicyError
+		validityErr *provisioners.ValidityError
+		keyErr      *provisioners.KeyError
+		csrErr      *provisioners.CSRError
+		certErr     *provisioners.CertificateError
+		rateErr     *provisioners.RateLimitError
+	)
+	switch {
+	case errors.As(err, &rateErr):
+		log.V(4).Info("certificate request rate limited by issuer", "retryAfter", rateErr.RetryAfter)
+
+		// The message does not include the delay, so waiting requests are not
+		// updated every time they are requeued.
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, fmt.Sprintf("Waiting to sign certificate request, rate limited by %s %s", kind, issNamespaceName))
+
+		return reconcile.Result{RequeueAfter: rateErr.RetryAfter}, nil
+	case errors.As(err, &policyErr):
+		log.Info("certificate request denied by issuer policy", "hostname", policyErr.Hostname, "reason", policyErr.Error())
+
+		return reconcile.Result{}, r.deny(ctx, cr, kind, issNamespaceName, reqType, "PolicyViolation", fmt.Sprintf("Denied by %s %s policy: %v", kind, issNamespaceName, policyErr))
+	case errors.As(err, &validityErr):
+		log.Info("certificate request denied by issuer validity policy", "duration", validityErr.Requested, "reason", validityErr.Error())
+
+		return reconcile.Result{}, r.deny(ctx, cr, kind, issNamespaceName, reqType, "ValidityViolation", fmt.Sprintf("Denied by %s %s validity policy: %v", kind, issNamespaceName, validityErr))
+	case errors.As(err, &keyErr), errors.As(err, &csrErr):
+		log.Info("certificate request CSR cannot be signed", "reason", err.Error())
+
+		return reconcile.Result{}, r.deny(ctx, cr, kind, issNamespaceName, reqType, string(cfapi.ErrorCategoryInvalidCSR), fmt.Sprintf("Failed to sign certificate request for %s %s: %v", kind, issNamespaceName, err))
+	case errors.As(err, &certErr):
+		log.Error(err, "certificate returned by the Cloudflare API failed verification", "id", certErr.ID)
+
+		return reconcile.Result{}, r.deny(ctx, cr, kind, issNamespaceName, reqType, "InvalidCertificate", fmt.Sprintf("Failed to verify certificate issued for %s %s: %v", kind, issNamespaceName, certErr))
+	}
+
 	if err != nil {
-		log.Error(err, "failed to sign certificate request")
-		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonFailed, fmt.Sprintf("Failed to sign certificate request: %v", err))
+		category := cfapi.Classify(err)
+		log.Error(err, "failed to sign certificate request", "category", category)
+		metrics.SignTotal.WithLabelValues(kind, issuerLabel(issNamespaceName), string(reqType), string(category)).Inc()
+
+		// Transient failures leave the request Pending, and returning the error
+		// requeues it with backoff. Only permanent failures are terminal.
+		if category.Transient() {
+			message := fmt.Sprintf("Failed to sign certificate request, will retry (%s): %v", category, err)
+			r.Recorder.Event(cr, core.EventTypeWarning, string(category), message)
+			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonPending, message)
+
+			return reconcile.Result{}, err
+		}
+
+		if cr.Status.FailureTime == nil {
+			nowTime := metav1.NewTime(r.Clock.Now())
+			cr.Status.FailureTime = &nowTime
+		}
+
+		message := fmt.Sprintf("Failed to sign certificate request (%s): %v", category, err)
+		r.Recorder.Event(cr, core.EventTypeWarning, string(category), message)
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonFailed, message)
 
 		return reconcile.Result{}, err
 	}
 
-	cr.Status.Certificate = pem
-	_ = r.setStatus(ctx, cr, cmmeta.ConditionTrue, certmanager.CertificateRequestReasonIssued, "Certificate issued")
+	log.Info("signed certificate", "id", res.ID, "expiration", res.Expiration, "validity", res.Validity)
+	metrics.SignTotal.WithLabelValues(kind, issuerLabel(issNamespaceName), string(reqType), certmanager.CertificateRequestReasonIssued).Inc()
+	metrics.IssuanceDuration.WithLabelValues(kind).Observe(r.Clock.Since(cr.CreationTimestamp.Time).Seconds())
+
+	// Record the certificate details before the status, so the ID is available to
+	// revoke the certificate once it has been superseded. Both are patched rather
+	// than updated, so a stale cached copy of the request cannot lose the
+	// certificate to a conflict.
+	patch := client.MergeFrom(cr.DeepCopy())
+	setSignResultAnnotations(cr, res)
+	if err := r.Client.Patch(ctx, cr, patch); err != nil {
+		log.Error(err, "failed to record certificate details", "id", res.ID)
+
+		return reconcile.Result{}, err
+	}
+
+	r.Recorder.Eventf(cr, core.EventTypeNormal, "Issued", "Certificate %s issued with validity of %d days, expiring %s", res.ID, res.Validity, res.Expiration.UTC().Format(time.RFC3339))
+
+	patch = client.MergeFrom(cr.DeepCopy())
+	cr.Status.Certificate = res.Certificate
+	cr.Status.CA = res.CA
+	cmutil.SetCertificateRequestCondition(cr, certmanager.CertificateRequestConditionReady, cmmeta.ConditionTrue, certmanager.CertificateRequestReasonIssued, "Certificate issued")
+	if err := r.Client.Status().Patch(ctx, cr, patch); err != nil {
+		log.Error(err, "failed to record signed certificate", "id", res.ID)
+
+		return reconcile.Result{}, err
+	}
 
 	return reconcile.Result{}, nil
 }
 
+// setSignResultAnnotations records the details of a signed certificate as annotations
+// on the CertificateRequest.
+func setSignResultAnnotations(cr *certmanager.CertificateRequest, res *provisioners.SignResult) {
+	metav1.SetMetaDataAnnotation(&cr.ObjectMeta, v1.CertificateIDAnnotationKey, res.ID)
+	metav1.SetMetaDataAnnotation(&cr.ObjectMeta, v1.CertificateValidityAnnotationKey, strconv.Itoa(res.Validity))
+
+	if !res.Expiration.IsZero() {
+		metav1.SetMetaDataAnnotation(&cr.ObjectMeta, v1.CertificateExpirationAnnotationKey, res.Expiration.UTC().Format(time.RFC3339))
+	}
+}
+
+// supportedUsages are the key usages of certificates signed by Origin CA.
+var supportedUsages = map[certmanager.KeyUsage]bool{
+	certmanager.UsageSigning:          true,
+	certmanager.UsageDigitalSignature: true,
+	certmanager.UsageKeyEncipherment:  true,
+	certmanager.UsageServerAuth:       true,
+	certmanager.UsageClientAuth:       true,
+}
+
+// checkSupported returns an error if the CertificateRequest asks for a CA
+// certificate, or key usages Origin CA certificates do not have.
+func checkSupported(cr *certmanager.CertificateRequest) error {
+	if cr.Spec.IsCA {
+		return errors.New("Origin CA does not sign CA certificates")
+	}
+
+	var unsupported []string
+	for _, usage := range cr.Spec.Usages {
+		if !supportedUsages[usage] {
+			unsupported = append(unsupported, string(usage))
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("Origin CA does not support key usages %q", unsupported)
+	}
+
+	return nil
+}
+
+// deny fails a CertificateRequest the issuer's configuration does not allow to be
+// signed. Retrying cannot succeed until the request or the issuer changes, so the
+// request is failed, leaving cert-manager to create a new one.
+func (r *CertificateRequestController) deny(ctx context.Context, cr *certmanager.CertificateRequest, kind string, issNamespaceName types.NamespacedName, reqType v1.RequestType, outcome, message string) error {
+	metrics.SignTotal.WithLabelValues(kind, issuerLabel(issNamespaceName), string(reqType), outcome).Inc()
+
+	if cr.Status.FailureTime == nil {
+		nowTime := metav1.NewTime(r.Clock.Now())
+		cr.Status.FailureTime = &nowTime
+	}
+
+	r.Recorder.Event(cr, core.EventTypeWarning, outcome, message)
+
+	return r.setStatus(ctx, cr, cmmeta.ConditionFalse, certmanager.CertificateRequestReasonFailed, message)
+}
+
 // setStatus is a helper function to set the CertifcateRequest status condition with reason and message, and update the API.
 func (r *CertificateRequestController) setStatus(ctx context.Context, cr *certmanager.CertificateRequest, status cmmeta.ConditionStatus, reason, message string) error {
 	cmutil.SetCertificateRequestCondition(cr, certmanager.CertificateRequestConditionReady, status, reason, message)
@@ -3,6 +3,8 @@ package controllers
 import (
 	"context"
 	"crypto/x509"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
@@ -12,15 +14,23 @@ import (
 	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
 	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	"github.com/cloudflare/origin-ca-issuer/internal/metrics"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
 	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	fakeClock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -39,6 +49,77 @@ func TestCertificateRequestReconcile(t *testing.T) {
 
 	cmutil.Clock = clock
 
+	failingObjects := func(mods ...cmgen.CSRModifier) []runtime.Object {
+		return []runtime.Object{
+			cmgen.CertificateRequest("foobar",
+				cmgen.SetCertificateRequestNamespace("default"),
+				cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+				cmgen.SetCertificateRequestCSR((func() []byte {
+					csr, _, err := cmgen.CSR(x509.ECDSA, append([]cmgen.CSRModifier{cmgen.SetCSRDNSNames("example.com")}, mods...)...)
+					if err != nil {
+						t.Fatalf("creating CSR: %s", err)
+					}
+
+					return csr
+				})()),
+				cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
+					Name:  "foobar",
+					Kind:  "OriginIssuer",
+					Group: "cert-manager.k8s.cloudflare.com",
+				}),
+			),
+			&v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foobar",
+					Namespace: "default",
+				},
+				Status: v1.OriginIssuerStatus{
+					Conditions: []v1.OriginIssuerCondition{
+						{
+							Type:   v1.ConditionReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	ca, err := fakeapi.NewAuthority("ECC", clock.Now())
+	if err != nil {
+		t.Fatalf("creating authority: %s", err)
+	}
+
+	csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+	if err != nil {
+		t.Fatalf("creating CSR: %s", err)
+	}
+
+	cert, err := ca.SignCSR(csr, []string{"example.com"}, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signing CSR: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM())
+
+	failingCollection := func(signErr error, opts ...provisioners.Option) *provisioners.Collection {
+		p, err := provisioners.New(&fakeapi.FakeClient{Error: signErr}, v1.RequestTypeOriginECC, logf.Log, opts...)
+		if err != nil {
+			t.Fatalf("error creating provisioner: %s", err)
+		}
+
+		return provisioners.CollectionWith([]provisioners.CollectionItem{
+			{
+				NamespacedName: types.NamespacedName{
+					Name:      "foobar",
+					Namespace: "default",
+				},
+				Provisioner: p,
+			},
+		})
+	}
+
 	tests := []struct {
 		name          string
 		objects       []runtime.Object
@@ -46,6 +127,9 @@ func TestCertificateRequestReconcile(t *testing.T) {
 		expected      cmapi.CertificateRequestStatus
 		error         string
 		namespaceName types.NamespacedName
+		issuerName    *types.NamespacedName
+		outcome       string
+		event         string
 	}{
 		{
 			name: "working",
@@ -53,14 +137,7 @@ func TestCertificateRequestReconcile(t *testing.T) {
 				cmgen.CertificateRequest("foobar",
 					cmgen.SetCertificateRequestNamespace("default"),
 					cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
-					cmgen.SetCertificateRequestCSR((func() []byte {
-						csr, _, err := cmgen.CSR(x509.ECDSA)
-						if err != nil {
-							t.Fatalf("creating CSR: %s", err)
-						}
-
-						return csr
-					})()),
+					cmgen.SetCertificateRequestCSR(csr),
 					cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
 						Name:  "foobar",
 						Kind:  "OriginIssuer",
@@ -100,15 +177,15 @@ func TestCertificateRequestReconcile(t *testing.T) {
 						c := &fakeapi.FakeClient{
 							Response: &cfapi.SignResponse{
 								Id:          "1",
-								Certificate: "bogus",
+								Certificate: string(cert),
 								Hostnames:   []string{"example.com"},
-								Expiration:  time.Time{},
+								Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
 								Type:        "colemak",
 								Validity:    0,
 								CSR:         "foobar",
 							},
 						}
-						p, err := provisioners.New(c, v1.RequestTypeOriginRSA, logf.Log)
+						p, err := provisioners.New(c, v1.RequestTypeOriginECC, logf.Log, provisioners.WithRoots(roots))
 						if err != nil {
 							t.Fatalf("error creating provisioner: %s", err)
 						}
@@ -127,12 +204,379 @@ func TestCertificateRequestReconcile(t *testing.T) {
 						Message:            "Certificate issued",
 					},
 				},
-				Certificate: []byte("bogus"),
+				Certificate: cert,
+				CA:          ca.PEM(),
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "Issued",
+		},
+		{
+			name: "working with cluster issuer",
+			objects: []runtime.Object{
+				cmgen.CertificateRequest("foobar",
+					cmgen.SetCertificateRequestNamespace("default"),
+					cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+					cmgen.SetCertificateRequestCSR(csr),
+					cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
+						Name:  "foobar",
+						Kind:  "ClusterOriginIssuer",
+						Group: "cert-manager.k8s.cloudflare.com",
+					}),
+				),
+				&v1.ClusterOriginIssuer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "foobar",
+					},
+					Spec: v1.OriginIssuerSpec{
+						Auth: v1.OriginIssuerAuthentication{
+							ServiceKeyRef: v1.SecretKeySelector{
+								Name: "service-key-issuer",
+								Key:  "key",
+							},
+						},
+					},
+					Status: v1.OriginIssuerStatus{
+						Conditions: []v1.OriginIssuerCondition{
+							{
+								Type:   v1.ConditionReady,
+								Status: v1.ConditionTrue,
+							},
+						},
+					},
+				},
+			},
+			collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+				{
+					NamespacedName: types.NamespacedName{
+						Name: "foobar",
+					},
+					Provisioner: (func() *provisioners.Provisioner {
+						c := &fakeapi.FakeClient{
+							Response: &cfapi.SignResponse{
+								Id:          "1",
+								Certificate: string(cert),
+								Hostnames:   []string{"example.com"},
+								Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+								Type:        "colemak",
+								Validity:    0,
+								CSR:         "foobar",
+							},
+						}
+						p, err := provisioners.New(c, v1.RequestTypeOriginECC, logf.Log)
+						if err != nil {
+							t.Fatalf("error creating provisioner: %s", err)
+						}
+
+						return p
+					}()),
+				},
+			}),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionTrue,
+						LastTransitionTime: &now,
+						Reason:             "Issued",
+						Message:            "Certificate issued",
+					},
+				},
+				Certificate: cert,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			issuerName: &types.NamespacedName{
+				Name: "foobar",
+			},
+			outcome: "Issued",
+		},
+		{
+			name:       "transient signing error",
+			objects:    failingObjects(),
+			collection: failingCollection(&cfapi.APIError{Code: 971, Message: "Please wait and consider throttling your request speed", StatusCode: 429}),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Pending",
+						Message:            "Failed to sign certificate request, will retry (RateLimited): unable to sign request: Cloudflare API Error code=971 message=Please wait and consider throttling your request speed ray_id=",
+					},
+				},
 			},
+			error: "unable to sign request: Cloudflare API Error code=971 message=Please wait and consider throttling your request speed ray_id=",
 			namespaceName: types.NamespacedName{
 				Namespace: "default",
 				Name:      "foobar",
 			},
+			outcome: "RateLimited",
+			event:   "Warning RateLimited Failed to sign certificate request, will retry (RateLimited): unable to sign request: Cloudflare API Error code=971 message=Please wait and consider throttling your request speed ray_id=",
+		},
+		{
+			name:       "permanent signing error",
+			objects:    failingObjects(),
+			collection: failingCollection(&cfapi.APIError{Code: 1010, Message: "Failed to validate requested hostname", StatusCode: 400}),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to sign certificate request (HostnameNotInAccount): unable to sign request: Cloudflare API Error code=1010 message=Failed to validate requested hostname ray_id=",
+					},
+				},
+				FailureTime: &now,
+			},
+			error: "unable to sign request: Cloudflare API Error code=1010 message=Failed to validate requested hostname ray_id=",
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "HostnameNotInAccount",
+			event:   "Warning HostnameNotInAccount Failed to sign certificate request (HostnameNotInAccount): unable to sign request: Cloudflare API Error code=1010 message=Failed to validate requested hostname ray_id=",
+		},
+		{
+			name:    "denied by issuer policy",
+			objects: failingObjects(cmgen.SetCSRDNSNames("www.example.com", "db.internal.example.com")),
+			collection: failingCollection(errors.New("unexpected call to the Cloudflare API"), provisioners.WithPolicy(&v1.OriginIssuerPolicy{
+				AllowedDomains: []string{"*.example.com"},
+				DeniedDomains:  []string{"*.internal.example.com"},
+			})),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            `Denied by OriginIssuer default/foobar policy: hostname "db.internal.example.com" matches denied domain "*.internal.example.com"`,
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "PolicyViolation",
+			event:   `Warning PolicyViolation Denied by OriginIssuer default/foobar policy: hostname "db.internal.example.com" matches denied domain "*.internal.example.com"`,
+		},
+		{
+			name:       "denied by issuer validity policy",
+			objects:    failingObjects(),
+			collection: failingCollection(errors.New("unexpected call to the Cloudflare API"), provisioners.WithValidity(v1.ValidityPolicyStrict, 30, 0)),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Denied by OriginIssuer default/foobar validity policy: requested duration 168h0m0s is not one of the allowed validities [30 90 365 730 1095 5475] (in days)",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "ValidityViolation",
+		},
+		{
+			name:    "csr key does not match request type",
+			objects: failingObjects(),
+			collection: (func() *provisioners.Collection {
+				p, err := provisioners.New(&fakeapi.FakeClient{Error: errors.New("unexpected call to the Cloudflare API")}, v1.RequestTypeOriginRSA, logf.Log)
+				if err != nil {
+					t.Fatalf("error creating provisioner: %s", err)
+				}
+
+				return provisioners.CollectionWith([]provisioners.CollectionItem{
+					{
+						NamespacedName: types.NamespacedName{
+							Name:      "foobar",
+							Namespace: "default",
+						},
+						Provisioner: p,
+					},
+				})
+			})(),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to sign certificate request for OriginIssuer default/foobar: invalid CSR: CSR cannot be signed with request type OriginRSA: ECDSA key requires request type OriginECC",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "InvalidCSR",
+		},
+		{
+			name:       "unsupported SAN",
+			objects:    failingObjects(cmgen.SetCSRIPAddresses(net.ParseIP("192.0.2.1"))),
+			collection: failingCollection(errors.New("unexpected call to the Cloudflare API")),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to sign certificate request for OriginIssuer default/foobar: invalid CSR: IP address SANs are not supported: [192.0.2.1]",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "InvalidCSR",
+		},
+		{
+			name:    "invalid certificate",
+			objects: failingObjects(),
+			collection: (func() *provisioners.Collection {
+				c := &fakeapi.FakeClient{
+					Response: &cfapi.SignResponse{
+						Id:          "1",
+						Certificate: string(cert),
+						Hostnames:   []string{"example.com"},
+						Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+					},
+				}
+				p, err := provisioners.New(c, v1.RequestTypeOriginECC, logf.Log)
+				if err != nil {
+					t.Fatalf("error creating provisioner: %s", err)
+				}
+
+				return provisioners.CollectionWith([]provisioners.CollectionItem{
+					{
+						NamespacedName: types.NamespacedName{
+							Name:      "foobar",
+							Namespace: "default",
+						},
+						Provisioner: p,
+					},
+				})
+			})(),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to verify certificate issued for OriginIssuer default/foobar: certificate 1 returned by the Cloudflare API failed verification: public key does not match the CSR",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "InvalidCertificate",
+		},
+		{
+			name: "ca certificate",
+			objects: (func() []runtime.Object {
+				objects := failingObjects()
+				objects[0].(*cmapi.CertificateRequest).Spec.IsCA = true
+
+				return objects
+			})(),
+			collection: failingCollection(errors.New("unexpected call to the Cloudflare API")),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not sign CA certificates",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "Unsupported",
+			event:   "Warning Unsupported Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not sign CA certificates",
+		},
+		{
+			name: "ca certificate with missing issuer",
+			objects: (func() []runtime.Object {
+				objects := failingObjects()
+				objects[0].(*cmapi.CertificateRequest).Spec.IsCA = true
+
+				return objects[:1]
+			})(),
+			collection: provisioners.CollectionWith(nil),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            "Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not sign CA certificates",
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "Unsupported",
+			event:   "Warning Unsupported Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not sign CA certificates",
+		},
+		{
+			name: "unsupported key usages",
+			objects: (func() []runtime.Object {
+				objects := failingObjects()
+				objects[0].(*cmapi.CertificateRequest).Spec.Usages = []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageServerAuth, cmapi.UsageCodeSigning, cmapi.UsageCRLSign}
+
+				return objects
+			})(),
+			collection: failingCollection(errors.New("unexpected call to the Cloudflare API")),
+			expected: cmapi.CertificateRequestStatus{
+				Conditions: []cmapi.CertificateRequestCondition{
+					{
+						Type:               cmapi.CertificateRequestConditionReady,
+						Status:             cmmeta.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Failed",
+						Message:            `Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not support key usages ["code signing" "crl sign"]`,
+					},
+				},
+				FailureTime: &now,
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foobar",
+			},
+			outcome: "Unsupported",
+			event:   `Warning Unsupported Failed to sign certificate request for OriginIssuer default/foobar: Origin CA does not support key usages ["code signing" "crl sign"]`,
 		},
 	}
 
@@ -145,16 +589,26 @@ func TestCertificateRequestReconcile(t *testing.T) {
 				WithStatusSubresource(&cmapi.CertificateRequest{}).
 				Build()
 
+			recorder := record.NewFakeRecorder(10)
+
 			controller := &CertificateRequestController{
 				Client:     client,
 				Log:        logf.Log,
+				Recorder:   recorder,
 				Collection: tt.collection,
+				Clock:      clock,
 			}
 
+			signed := signTotal(t, map[string]string{"outcome": tt.outcome})
+
 			_, err := reconcile.AsReconciler(client, controller).Reconcile(context.Background(), reconcile.Request{
 				NamespacedName: tt.namespaceName,
 			})
 
+			if diff := cmp.Diff(signTotal(t, map[string]string{"outcome": tt.outcome})-signed, float64(1)); diff != "" {
+				t.Fatalf("sign_total diff: (-got +want)\n%s", diff)
+			}
+
 			if err != nil {
 				if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
 					t.Fatalf("diff: (-wanted +got)\n%s", diff)
@@ -169,11 +623,465 @@ func TestCertificateRequestReconcile(t *testing.T) {
 				t.Fatalf("diff: (-want +got)\n%s", diff)
 			}
 
-			if tt.error == "" {
-				if _, ok := controller.Collection.Load(tt.namespaceName); !ok {
+			if tt.outcome == cmapi.CertificateRequestReasonIssued {
+				annotations := map[string]string{
+					v1.CertificateIDAnnotationKey:         got.Annotations[v1.CertificateIDAnnotationKey],
+					v1.CertificateExpirationAnnotationKey: got.Annotations[v1.CertificateExpirationAnnotationKey],
+					v1.CertificateValidityAnnotationKey:   got.Annotations[v1.CertificateValidityAnnotationKey],
+				}
+				if diff := cmp.Diff(annotations, map[string]string{
+					v1.CertificateIDAnnotationKey:         "1",
+					v1.CertificateExpirationAnnotationKey: "2030-01-01T00:00:00Z",
+					v1.CertificateValidityAnnotationKey:   "7",
+				}); diff != "" {
+					t.Fatalf("annotations diff: (-got +want)\n%s", diff)
+				}
+
+				select {
+				case event := <-recorder.Events:
+					if diff := cmp.Diff(event, "Normal Issued Certificate 1 issued with validity of 7 days, expiring 2030-01-01T00:00:00Z"); diff != "" {
+						t.Fatalf("event diff: (-got +want)\n%s", diff)
+					}
+				default:
+					t.Fatal("expected an event to be recorded")
+				}
+			}
+
+			if tt.event != "" {
+				select {
+				case event := <-recorder.Events:
+					if diff := cmp.Diff(event, tt.event); diff != "" {
+						t.Fatalf("event diff: (-got +want)\n%s", diff)
+					}
+				default:
+					t.Fatal("expected an event to be recorded")
+				}
+			}
+
+			issuerName := tt.namespaceName
+			if tt.issuerName != nil {
+				issuerName = *tt.issuerName
+			}
+
+			if tt.outcome == cmapi.CertificateRequestReasonIssued {
+				if _, ok := controller.Collection.Load(issuerName); !ok {
 					t.Fatal("was unable to find provisioner")
 				}
 			}
 		})
 	}
 }
+
+func TestCertificateRequestReconcile_RateLimited(t *testing.T) {
+	if err := cmapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	now := metav1.NewTime(clock.Now())
+
+	cmutil.Clock = clock
+
+	ca, err := fakeapi.NewAuthority("ECC", clock.Now())
+	if err != nil {
+		t.Fatalf("creating authority: %s", err)
+	}
+
+	csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+	if err != nil {
+		t.Fatalf("creating CSR: %s", err)
+	}
+
+	cert, err := ca.SignCSR(csr, []string{"example.com"}, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signing CSR: %s", err)
+	}
+
+	request := func(name string) *cmapi.CertificateRequest {
+		return cmgen.CertificateRequest(name,
+			cmgen.SetCertificateRequestNamespace("default"),
+			cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+			cmgen.SetCertificateRequestCSR(csr),
+			cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
+				Name:  "foobar",
+				Kind:  "OriginIssuer",
+				Group: "cert-manager.k8s.cloudflare.com",
+			}),
+		)
+	}
+
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithRuntimeObjects(
+			request("first"),
+			request("second"),
+			&v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foobar",
+					Namespace: "default",
+				},
+				Status: v1.OriginIssuerStatus{
+					Conditions: []v1.OriginIssuerCondition{
+						{
+							Type:   v1.ConditionReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+		).
+		WithStatusSubresource(&cmapi.CertificateRequest{}).
+		Build()
+
+	p, err := provisioners.New(&fakeapi.FakeClient{
+		Response: &cfapi.SignResponse{
+			Id:          "1",
+			Certificate: string(cert),
+			Hostnames:   []string{"example.com"},
+			Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, v1.RequestTypeOriginECC, logf.Log, provisioners.WithRateLimit(1, 1))
+	if err != nil {
+		t.Fatalf("error creating provisioner: %s", err)
+	}
+
+	controller := &CertificateRequestController{
+		Client:   client,
+		Log:      logf.Log,
+		Recorder: record.NewFakeRecorder(10),
+		Collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+			{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "foobar"},
+				Provisioner:    p,
+			},
+		}),
+		Clock: clock,
+	}
+
+	for _, tt := range []struct {
+		name     string
+		requeue  bool
+		expected []cmapi.CertificateRequestCondition
+	}{
+		{
+			name: "first",
+			expected: []cmapi.CertificateRequestCondition{
+				{
+					Type:               cmapi.CertificateRequestConditionReady,
+					Status:             cmmeta.ConditionTrue,
+					LastTransitionTime: &now,
+					Reason:             "Issued",
+					Message:            "Certificate issued",
+				},
+			},
+		},
+		{
+			name:    "second",
+			requeue: true,
+			expected: []cmapi.CertificateRequestCondition{
+				{
+					Type:               cmapi.CertificateRequestConditionReady,
+					Status:             cmmeta.ConditionFalse,
+					LastTransitionTime: &now,
+					Reason:             "Pending",
+					Message:            "Waiting to sign certificate request, rate limited by OriginIssuer default/foobar",
+				},
+			},
+		},
+	} {
+		name := types.NamespacedName{Namespace: "default", Name: tt.name}
+
+		res, err := reconcile.AsReconciler(client, controller).Reconcile(context.Background(), reconcile.Request{NamespacedName: name})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+
+		if requeue := res.RequeueAfter > 0; requeue != tt.requeue {
+			t.Fatalf("%s: expected requeue %t, got %s", tt.name, tt.requeue, res.RequeueAfter)
+		}
+
+		got := &cmapi.CertificateRequest{}
+		if err := client.Get(context.TODO(), name, got); err != nil {
+			t.Fatalf("expected to retrieve certificate request from client: %s", err)
+		}
+
+		if diff := cmp.Diff(got.Status.Conditions, tt.expected); diff != "" {
+			t.Fatalf("%s: diff: (-got +want)\n%s", tt.name, diff)
+		}
+	}
+}
+
+func TestCertificateRequestReconcile_ResolvedRequestType(t *testing.T) {
+	if err := cmapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	cmutil.Clock = clock
+
+	ca, err := fakeapi.NewAuthority("ECC", clock.Now())
+	if err != nil {
+		t.Fatalf("creating authority: %s", err)
+	}
+
+	csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+	if err != nil {
+		t.Fatalf("creating CSR: %s", err)
+	}
+
+	cert, err := ca.SignCSR(csr, []string{"example.com"}, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signing CSR: %s", err)
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithRuntimeObjects(
+			cmgen.CertificateRequest("foobar",
+				cmgen.SetCertificateRequestNamespace("default"),
+				cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+				cmgen.SetCertificateRequestCSR(csr),
+				cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
+					Name:  "auto",
+					Kind:  "OriginIssuer",
+					Group: "cert-manager.k8s.cloudflare.com",
+				}),
+			),
+			&v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "auto",
+					Namespace: "default",
+				},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeAuto,
+				},
+				Status: v1.OriginIssuerStatus{
+					Conditions: []v1.OriginIssuerCondition{
+						{
+							Type:   v1.ConditionReady,
+							Status: v1.ConditionTrue,
+						},
+					},
+				},
+			},
+		).
+		WithStatusSubresource(&cmapi.CertificateRequest{}).
+		Build()
+
+	p, err := provisioners.New(&fakeapi.FakeClient{
+		Response: &cfapi.SignResponse{
+			Id:          "1",
+			Certificate: string(cert),
+			Hostnames:   []string{"example.com"},
+			Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, v1.RequestTypeAuto, logf.Log)
+	if err != nil {
+		t.Fatalf("error creating provisioner: %s", err)
+	}
+
+	controller := &CertificateRequestController{
+		Client:   c,
+		Log:      logf.Log,
+		Recorder: record.NewFakeRecorder(10),
+		Collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+			{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "auto"},
+				Provisioner:    p,
+			},
+		}),
+		Clock: clock,
+	}
+
+	labels := func(reqType v1.RequestType) map[string]string {
+		return map[string]string{"issuer": "default/auto", "request_type": string(reqType), "outcome": cmapi.CertificateRequestReasonIssued}
+	}
+
+	ecc, auto := signTotal(t, labels(v1.RequestTypeOriginECC)), signTotal(t, labels(v1.RequestTypeAuto))
+
+	_, err = reconcile.AsReconciler(c, controller).Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "foobar"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(signTotal(t, labels(v1.RequestTypeOriginECC))-ecc, float64(1)); diff != "" {
+		t.Fatalf("sign_total diff: (-got +want)\n%s", diff)
+	}
+
+	if diff := cmp.Diff(signTotal(t, labels(v1.RequestTypeAuto))-auto, float64(0)); diff != "" {
+		t.Fatalf("sign_total diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestCertificateRequestReconcile_WriteFailures(t *testing.T) {
+	if err := cmapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	cmutil.Clock = clock
+
+	ca, err := fakeapi.NewAuthority("ECC", clock.Now())
+	if err != nil {
+		t.Fatalf("creating authority: %s", err)
+	}
+
+	csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+	if err != nil {
+		t.Fatalf("creating CSR: %s", err)
+	}
+
+	cert, err := ca.SignCSR(csr, []string{"example.com"}, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signing CSR: %s", err)
+	}
+
+	conflict := apierrors.NewConflict(schema.GroupResource{Group: "cert-manager.io", Resource: "certificaterequests"}, "foobar", errors.New("the object has been modified"))
+
+	tests := []struct {
+		name        string
+		funcs       interceptor.Funcs
+		annotations bool
+	}{
+		{
+			name: "annotations",
+			funcs: interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					return conflict
+				},
+			},
+		},
+		{
+			name: "status",
+			funcs: interceptor.Funcs{
+				SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+					return conflict
+				},
+			},
+			annotations: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(
+					cmgen.CertificateRequest("foobar",
+						cmgen.SetCertificateRequestNamespace("default"),
+						cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+						cmgen.SetCertificateRequestCSR(csr),
+						cmgen.SetCertificateRequestIssuer(cmmeta.ObjectReference{
+							Name:  "foobar",
+							Kind:  "OriginIssuer",
+							Group: "cert-manager.k8s.cloudflare.com",
+						}),
+					),
+					&v1.OriginIssuer{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foobar",
+							Namespace: "default",
+						},
+						Status: v1.OriginIssuerStatus{
+							Conditions: []v1.OriginIssuerCondition{
+								{
+									Type:   v1.ConditionReady,
+									Status: v1.ConditionTrue,
+								},
+							},
+						},
+					},
+				).
+				WithStatusSubresource(&cmapi.CertificateRequest{}).
+				WithInterceptorFuncs(tt.funcs).
+				Build()
+
+			p, err := provisioners.New(&fakeapi.FakeClient{
+				Response: &cfapi.SignResponse{
+					Id:          "1",
+					Certificate: string(cert),
+					Hostnames:   []string{"example.com"},
+					Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+				},
+			}, v1.RequestTypeOriginECC, logf.Log)
+			if err != nil {
+				t.Fatalf("error creating provisioner: %s", err)
+			}
+
+			controller := &CertificateRequestController{
+				Client:   c,
+				Log:      logf.Log,
+				Recorder: record.NewFakeRecorder(10),
+				Collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+					{
+						NamespacedName: types.NamespacedName{Namespace: "default", Name: "foobar"},
+						Provisioner:    p,
+					},
+				}),
+				Clock: clock,
+			}
+
+			name := types.NamespacedName{Namespace: "default", Name: "foobar"}
+			_, err = reconcile.AsReconciler(c, controller).Reconcile(context.Background(), reconcile.Request{NamespacedName: name})
+			if !apierrors.IsConflict(err) {
+				t.Fatalf("expected the conflict to be returned, got %v", err)
+			}
+
+			got := &cmapi.CertificateRequest{}
+			if err := c.Get(context.TODO(), name, got); err != nil {
+				t.Fatalf("expected to retrieve certificate request from client: %s", err)
+			}
+
+			if len(got.Status.Certificate) > 0 {
+				t.Fatal("expected no certificate to be recorded")
+			}
+
+			if _, ok := got.Annotations[v1.CertificateIDAnnotationKey]; ok != tt.annotations {
+				t.Fatalf("expected certificate ID annotation to be recorded: %t", tt.annotations)
+			}
+		})
+	}
+}
+
+// signTotal returns the sum of the sign_total counters with the given labels.
+func signTotal(t *testing.T, labels map[string]string) float64 {
+	ch := make(chan prometheus.Metric, 100)
+	metrics.SignTotal.Collect(ch)
+	close(ch)
+
+	var total float64
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatalf("writing metric: %s", err)
+		}
+
+		matched := 0
+		for _, l := range pb.GetLabel() {
+			if v, ok := labels[l.GetName()]; ok && l.GetValue() == v {
+				matched++
+			}
+		}
+
+		if matched == len(labels) {
+			total += pb.GetCounter().GetValue()
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClusterOriginIssuerController implements a controller that watches for changes
+// to ClusterOriginIssuer resources.
+type ClusterOriginIssuerController struct {
+	client.Client
+	Log        logr.Logger
+	Recorder   record.EventRecorder
+	Clock      clock.Clock
+	Factory    cfapi.Factory
+	Collection *provisioners.Collection
+
+	// Roots are the Origin CA root certificates, which certificates returned by
+	// the Cloudflare API must chain to. If nil, the embedded roots are used.
+	Roots *x509.CertPool
+
+	// ProbeInterval is how often issuer credentials are verified against the
+	// Cloudflare API. Zero disables periodic verification.
+	ProbeInterval time.Duration
+
+	// AllowEndpoints accepts issuers overriding the Cloudflare API endpoint.
+	AllowEndpoints bool
+
+	// RateLimit limits how often issuers without a spec.rateLimit sign
+	// certificates. A zero RequestsPerMinute leaves them unlimited.
+	RateLimit v1.RateLimit
+
+	// Scope restricts the issuers reconciled. Issuers outside of it have no
+	// provisioner.
+	Scope Scope
+
+	// ClusterResourceNamespace is the namespace secrets referenced by
+	// ClusterOriginIssuers are read from.
+	ClusterResourceNamespace string
+}
+
+// Reconcile reconciles ClusterOriginIssuer resources by managing Cloudflare API provisioners.
+// Provisioners are stored in the collection keyed only by the issuer's name.
+func (r *ClusterOriginIssuerController) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.Log.WithValues("clusteroriginissuer", req.Name)
+
+	return r.reconciler().reconcile(ctx, &v1.ClusterOriginIssuer{}, types.NamespacedName{Name: req.Name}, r.ClusterResourceNamespace, log)
+}
+
+// IssuersForSecret is a handler.MapFunc returning requests for the
+// ClusterOriginIssuers referencing the Secret, if it is in the cluster resource
+// namespace.
+func (r *ClusterOriginIssuerController) IssuersForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	return r.issuersFor(ctx, secret, SecretIndexField)
+}
+
+// IssuersForConfigMap is a handler.MapFunc returning requests for the
+// ClusterOriginIssuers referencing the ConfigMap for their CA bundle, if it is
+// in the cluster resource namespace.
+func (r *ClusterOriginIssuerController) IssuersForConfigMap(ctx context.Context, cm client.Object) []reconcile.Request {
+	return r.issuersFor(ctx, cm, ConfigMapIndexField)
+}
+
+// issuersFor returns requests for the ClusterOriginIssuers indexed by the
+// object's name in field, if it is in the cluster resource namespace.
+func (r *ClusterOriginIssuerController) issuersFor(ctx context.Context, obj client.Object, field string) []reconcile.Request {
+	if obj.GetNamespace() != r.ClusterResourceNamespace {
+		return nil
+	}
+
+	issuers := v1.ClusterOriginIssuerList{}
+	if err := r.Client.List(ctx, &issuers, client.MatchingFields{field: obj.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list ClusterOriginIssuers referencing object", "field", field, "namespace", obj.GetNamespace(), "name", obj.GetName())
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(issuers.Items))
+	for _, iss := range issuers.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: iss.Name},
+		})
+	}
+
+	return requests
+}
+
+func (r *ClusterOriginIssuerController) reconciler() *issuerReconciler {
+	return &issuerReconciler{
+		Client:     r.Client,
+		Kind:       "ClusterOriginIssuer",
+		Log:        r.Log,
+		Recorder:   r.Recorder,
+		Clock:      r.Clock,
+		Factory:    r.Factory,
+		Collection: r.Collection,
+		Roots:      r.Roots,
+
+		ProbeInterval:  r.ProbeInterval,
+		AllowEndpoints: r.AllowEndpoints,
+		RateLimit:      r.RateLimit,
+		Scope:          r.Scope,
+	}
+}
@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SecretIndexField is the name of the field index on OriginIssuers and
+// ClusterOriginIssuers of the Secret holding their credentials.
+const SecretIndexField = ".spec.auth.secretName"
+
+// ConfigMapIndexField is the name of the field index on OriginIssuers and
+// ClusterOriginIssuers of the ConfigMap holding their CA bundle.
+const ConfigMapIndexField = ".spec.caBundleRef.name"
+
+// IndexIssuerSecret is a client.IndexerFunc returning the name of the Secret
+// referenced by an OriginIssuer or ClusterOriginIssuer.
+func IndexIssuerSecret(obj client.Object) []string {
+	iss, ok := obj.(v1.GenericIssuer)
+	if !ok {
+		return nil
+	}
+
+	ref, _ := authSecretRef(iss.GetSpec().Auth)
+	if ref.Name == "" {
+		return nil
+	}
+
+	return []string{ref.Name}
+}
+
+// IndexIssuerConfigMap is a client.IndexerFunc returning the name of the ConfigMap
+// referenced by an OriginIssuer or ClusterOriginIssuer for its CA bundle.
+func IndexIssuerConfigMap(obj client.Object) []string {
+	iss, ok := obj.(v1.GenericIssuer)
+	if !ok {
+		return nil
+	}
+
+	ref := iss.GetSpec().CABundleRef
+	if ref == nil || ref.Name == "" {
+		return nil
+	}
+
+	return []string{ref.Name}
+}
+
+// issuerReconciler holds the reconciliation logic shared between the
+// OriginIssuer and ClusterOriginIssuer controllers.
+type issuerReconciler struct {
+	client.Client
+	Kind       string
+	Log        logr.Logger
+	Recorder   record.EventRecorder
+	Clock      clock.Clock
+	Factory    cfapi.Factory
+	Collection *provisioners.Collection
+
+	// Roots are the Origin CA root certificates, which certificates returned by
+	// the Cloudflare API must chain to. If nil, the embedded roots are used.
+	Roots *x509.CertPool
+
+	// ProbeInterval is how often the issuer's credentials are verified against
+	// the Cloudflare API. Zero disables periodic verification.
+	ProbeInterval time.Duration
+
+	// AllowEndpoints accepts issuers overriding the Cloudflare API endpoint.
+	AllowEndpoints bool
+
+	// RateLimit limits how often issuers without a spec.rateLimit sign
+	// certificates. A zero RequestsPerMinute leaves them unlimited.
+	RateLimit v1.RateLimit
+
+	// Scope restricts the issuers reconciled. Issuers outside of it have no
+	// provisioner.
+	Scope Scope
+}
+
+// reconcile retrieves the issuer with the given key into iss, validates it, loads
+// its credentials from secretNamespace, and stores a provisioner in the collection
+// with the same key. The provisioner is removed from the collection once the issuer
+// is deleted, or can no longer be provisioned, so the collection always matches
+// the cluster.
+func (r *issuerReconciler) reconcile(ctx context.Context, iss v1.GenericIssuer, key types.NamespacedName, secretNamespace string, log logr.Logger) (reconcile.Result, error) {
+	if err := r.Client.Get(ctx, key, iss); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.V(4).Info("issuer has been deleted, removing provisioner")
+			r.Collection.Delete(key)
+			r.Collection.DeleteRateLimiter(key)
+
+			return reconcile.Result{}, nil
+		}
+
+		log.Error(err, "failed to retrieve issuer")
+
+		return reconcile.Result{}, err
+	}
+
+	if !iss.GetDeletionTimestamp().IsZero() {
+		log.V(4).Info("issuer is being deleted, removing provisioner")
+		r.Collection.Delete(key)
+		r.Collection.DeleteRateLimiter(key)
+
+		return reconcile.Result{}, nil
+	}
+
+	if !r.Scope.Contains(iss) {
+		log.V(4).Info("issuer is not within the controller's scope, removing provisioner")
+		r.Collection.Delete(key)
+		r.Collection.DeleteRateLimiter(key)
+
+		return reconcile.Result{}, nil
+	}
+
+	p, err := r.provisioner(ctx, iss, key, secretNamespace, log)
+
+	// A verified issuer continues signing with its existing provisioner while the
+	// Cloudflare API is unreachable, rather than stopping until it recovers, as long
+	// as neither the issuer nor its credentials changed since it was verified.
+	var unreachable *unreachableError
+	if errors.As(err, &unreachable) {
+		if existing, ok := r.Collection.Load(key); ok && existing.Version() == unreachable.version && IssuerHasCondition(iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue}) {
+			log.Error(err, "failed to verify issuer credentials, continuing to use previously verified credentials")
+			r.Recorder.Event(iss, core.EventTypeWarning, "Unreachable", fmt.Sprintf("Failed to verify credentials with the Cloudflare API, continuing to use previously verified credentials: %v", err))
+
+			return reconcile.Result{}, err
+		}
+
+		log.Error(err, "failed to verify issuer credentials")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Unreachable", fmt.Sprintf("Failed to verify credentials with the Cloudflare API: %v", err))
+	}
+
+	if err != nil {
+		r.Collection.Delete(key)
+
+		return reconcile.Result{}, err
+	}
+
+	r.Collection.Store(key, p)
+
+	return reconcile.Result{RequeueAfter: r.ProbeInterval}, r.setStatus(ctx, iss, v1.ConditionTrue, "Verified", fmt.Sprintf("%s verified and ready to sign certificates", r.Kind))
+}
+
+// provisioner validates the issuer and returns a provisioner using the credentials
+// it references in secretNamespace, and the rate limiter stored in the collection
+// with key, updating the issuer's status on failure.
+func (r *issuerReconciler) provisioner(ctx context.Context, iss v1.GenericIssuer, key types.NamespacedName, secretNamespace string, log logr.Logger) (*provisioners.Provisioner, error) {
+	spec := iss.GetSpec()
+
+	if err := spec.Validate(); err != nil {
+		log.Error(err, "failed to validate issuer resource")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Invalid", fmt.Sprintf("Issuer is invalid: %v", err))
+
+		return nil, err
+	}
+
+	if spec.Endpoint != "" && !r.AllowEndpoints {
+		err := fmt.Errorf("endpoint %s is not allowed", spec.Endpoint)
+		log.Error(err, "issuer overrides the Cloudflare API endpoint")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "EndpointNotAllowed", fmt.Sprintf("Overriding the Cloudflare API endpoint is not enabled for this controller: %v", err))
+
+		return nil, err
+	}
+
+	ref, credentials := authSecretRef(spec.Auth)
+
+	secret := core.Secret{}
+	secretNamespaceName := types.NamespacedName{
+		Namespace: secretNamespace,
+		Name:      ref.Name,
+	}
+
+	if err := r.Client.Get(ctx, secretNamespaceName, &secret); err != nil {
+		log.Error(err, "failed to retieve issuer auth secret", "namespace", secretNamespaceName.Namespace, "name", secretNamespaceName.Name)
+
+		if apierrors.IsNotFound(err) {
+			_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
+		} else {
+			_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Error", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
+		}
+
+		return nil, err
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		err := fmt.Errorf("secret %s does not contain key %q", secret.Name, ref.Key)
+		log.Error(err, "failed to retrieve issuer auth secret")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
+
+		return nil, err
+	}
+
+	roots, err := r.roots(ctx, iss, secretNamespace, log)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := credentials(value)
+	creds.Endpoint = spec.Endpoint
+
+	c, err := r.Factory.APIWith(creds)
+	if err != nil {
+		log.Error(err, "failed to create API client")
+
+		return nil, err
+	}
+
+	version := fmt.Sprintf("%d/%s", iss.GetGeneration(), secret.ResourceVersion)
+
+	if err := r.verify(ctx, iss, c, log); err != nil {
+		var unreachable *unreachableError
+		if errors.As(err, &unreachable) {
+			unreachable.version = version
+		}
+
+		return nil, err
+	}
+
+	limit := r.RateLimit
+	if spec.RateLimit != nil {
+		limit = *spec.RateLimit
+	}
+
+	p, err := provisioners.New(c, spec.RequestType, log,
+		provisioners.WithPolicy(spec.Policy),
+		provisioners.WithValidity(spec.ValidityPolicy, spec.MinValidityDays, spec.MaxValidityDays),
+		provisioners.WithRoots(roots),
+		provisioners.WithRateLimiter(r.Collection.RateLimiter(key, limit.RequestsPerMinute, limit.Burst)),
+		provisioners.WithClock(r.Clock),
+		provisioners.WithVersion(version),
+	)
+	if err != nil {
+		log.Error(err, "failed to create provisioner")
+
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Error", "Failed initialize provisioner")
+
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// roots returns the Origin CA root certificates signed for the issuer must chain
+// to: its CA bundle, or the ConfigMap key it references in namespace, falling back
+// to the controller's roots. The issuer's status is updated on failure.
+func (r *issuerReconciler) roots(ctx context.Context, iss v1.GenericIssuer, namespace string, log logr.Logger) (*x509.CertPool, error) {
+	spec := iss.GetSpec()
+	bundle := spec.CABundle
+
+	if ref := spec.CABundleRef; ref != nil {
+		cm := core.ConfigMap{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &cm); err != nil {
+			log.Error(err, "failed to retrieve issuer CA bundle", "namespace", namespace, "name", ref.Name)
+
+			if apierrors.IsNotFound(err) {
+				_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve CA bundle: %v", err))
+			} else {
+				_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Error", fmt.Sprintf("Failed to retrieve CA bundle: %v", err))
+			}
+
+			return nil, err
+		}
+
+		data, ok := cm.Data[ref.Key]
+		if !ok {
+			err := fmt.Errorf("configmap %s does not contain key %q", cm.Name, ref.Key)
+			log.Error(err, "failed to retrieve issuer CA bundle")
+			_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve CA bundle: %v", err))
+
+			return nil, err
+		}
+
+		bundle = []byte(data)
+	}
+
+	if len(bundle) == 0 {
+		return r.Roots, nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundle) {
+		err := fmt.Errorf("CA bundle does not contain any PEM encoded certificates")
+		log.Error(err, "failed to load issuer CA bundle")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "InvalidCABundle", fmt.Sprintf("Failed to load CA bundle: %v", err))
+
+		return nil, err
+	}
+
+	return roots, nil
+}
+
+// unreachableError is returned when the issuer's credentials could not be verified
+// because the Cloudflare API could not be reached, or was briefly unavailable.
+type unreachableError struct {
+	err error
+
+	// version is the issuer generation and auth secret resource version that
+	// could not be verified.
+	version string
+}
+
+func (e *unreachableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unreachableError) Unwrap() error {
+	return e.err
+}
+
+// verify probes the Cloudflare API with the issuer's credentials, updating the
+// issuer's status if they are rejected or cannot be verified. Transient failures
+// return an unreachableError without updating the status, leaving the caller to
+// decide whether previously verified credentials can still be used.
+func (r *issuerReconciler) verify(ctx context.Context, iss v1.GenericIssuer, c cfapi.Interface, log logr.Logger) error {
+	err := c.Verify(ctx)
+	if err == nil {
+		return nil
+	}
+
+	category := cfapi.Classify(err)
+	switch {
+	case category == cfapi.ErrorCategoryAuthRejected:
+		log.Error(err, "Cloudflare API rejected issuer credentials")
+		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "InvalidCredentials", fmt.Sprintf("Cloudflare API rejected credentials: %v", err))
+
+		return err
+	case category.Transient():
+		return &unreachableError{err: err}
+	}
+
+	log.Error(err, "failed to verify issuer credentials", "category", category)
+	_ = r.setStatus(ctx, iss, v1.ConditionFalse, "VerificationFailed", fmt.Sprintf("Failed to verify credentials with the Cloudflare API: %v", err))
+
+	return err
+}
+
+// setStatus is a helper function to set the issuer status condition with reason and message, and update the API.
+// An event is recorded when the condition's status or reason changes, so periodic
+// credential probes with the same result do not repeat it.
+func (r *issuerReconciler) setStatus(ctx context.Context, iss v1.GenericIssuer, status v1.ConditionStatus, reason, message string) error {
+	changed := true
+	for _, c := range iss.GetStatus().Conditions {
+		if c.Type == v1.ConditionReady && c.Status == status && c.Reason == reason {
+			changed = false
+		}
+	}
+
+	if changed {
+		eventType := core.EventTypeNormal
+		if status != v1.ConditionTrue {
+			eventType = core.EventTypeWarning
+		}
+
+		r.Recorder.Event(iss, eventType, reason, message)
+	}
+
+	SetIssuerCondition(iss, v1.ConditionReady, status, r.Log, r.Clock, reason, message)
+
+	return r.Client.Status().Update(ctx, iss)
+}
+
+// authSecretRef returns the secret reference for the configured authentication
+// method, along with a function building API credentials from the secret's value.
+func authSecretRef(auth v1.OriginIssuerAuthentication) (v1.SecretKeySelector, func([]byte) cfapi.Credentials) {
+	if auth.TokenRef.Name != "" {
+		return auth.TokenRef, func(token []byte) cfapi.Credentials {
+			return cfapi.Credentials{Token: token}
+		}
+	}
+
+	return auth.ServiceKeyRef, func(serviceKey []byte) cfapi.Credentials {
+		return cfapi.Credentials{ServiceKey: serviceKey}
+	}
+}
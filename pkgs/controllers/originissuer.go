@@ -2,15 +2,15 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"crypto/x509"
+	"time"
 
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
 	"github.com/go-logr/logr"
-	core "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -21,97 +21,96 @@ import (
 type OriginIssuerController struct {
 	client.Client
 	Log        logr.Logger
+	Recorder   record.EventRecorder
 	Clock      clock.Clock
 	Factory    cfapi.Factory
 	Collection *provisioners.Collection
+
+	// Roots are the Origin CA root certificates, which certificates returned by
+	// the Cloudflare API must chain to. If nil, the embedded roots are used.
+	Roots *x509.CertPool
+
+	// ProbeInterval is how often issuer credentials are verified against the
+	// Cloudflare API. Zero disables periodic verification.
+	ProbeInterval time.Duration
+
+	// AllowEndpoints accepts issuers overriding the Cloudflare API endpoint.
+	AllowEndpoints bool
+
+	// RateLimit limits how often issuers without a spec.rateLimit sign
+	// certificates. A zero RequestsPerMinute leaves them unlimited.
+	RateLimit v1.RateLimit
+
+	// Scope restricts the issuers reconciled. Issuers outside of it have no
+	// provisioner.
+	Scope Scope
 }
 
 //go:generate controller-gen rbac:roleName=originissuer-control paths=./. output:rbac:artifacts:config=../../deploy/rbac
 
 // +kubebuilder:rbac:groups=cert-manager.k8s.cloudflare.com,resources=originissuers,verbs=get;list;watch;create
 // +kubebuilder:rbac:groups=cert-manager.k8s.cloudflare.com,resources=originissuers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cert-manager.k8s.cloudflare.com,resources=clusteroriginissuers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cert-manager.k8s.cloudflare.com,resources=clusteroriginissuers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile reconciles OriginIssuer resources by managing Cloudflare API provisioners.
-func (r *OriginIssuerController) Reconcile(ctx context.Context, iss *v1.OriginIssuer) (reconcile.Result, error) {
-	log := r.Log.WithValues("namespace", iss.Namespace, "originissuer", iss.Name)
-
-	if err := validateOriginIssuer(iss.Spec); err != nil {
-		log.Error(err, "failed to validate OriginIssuer resource")
-
-		return reconcile.Result{}, err
-	}
-
-	secret := core.Secret{}
-	secretNamespaceName := types.NamespacedName{
-		Namespace: iss.Namespace,
-		Name:      iss.Spec.Auth.ServiceKeyRef.Name,
-	}
-
-	if err := r.Client.Get(ctx, secretNamespaceName, &secret); err != nil {
-		log.Error(err, "failed to retieve OriginIssuer auth secret", "namespace", secretNamespaceName.Namespace, "name", secretNamespaceName.Name)
-
-		if apierrors.IsNotFound(err) {
-			_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
-		} else {
-			_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Error", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
-		}
+func (r *OriginIssuerController) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.Log.WithValues("namespace", req.Namespace, "originissuer", req.Name)
 
-		return reconcile.Result{}, err
-	}
+	return r.reconciler().reconcile(ctx, &v1.OriginIssuer{}, req.NamespacedName, req.Namespace, log)
+}
 
-	serviceKey, ok := secret.Data[iss.Spec.Auth.ServiceKeyRef.Key]
-	if !ok {
-		err := fmt.Errorf("secret %s does not contain key %q", secret.Name, iss.Spec.Auth.ServiceKeyRef.Key)
-		log.Error(err, "failed to retrieve OriginIssuer auth secret")
-		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "NotFound", fmt.Sprintf("Failed to retrieve auth secret: %v", err))
+// IssuersForSecret is a handler.MapFunc returning requests for the OriginIssuers
+// in the Secret's namespace referencing it, so rotating or deleting credentials
+// rebuilds or removes their provisioners.
+func (r *OriginIssuerController) IssuersForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	return r.issuersFor(ctx, secret, SecretIndexField)
+}
 
-		return reconcile.Result{}, err
-	}
+// IssuersForConfigMap is a handler.MapFunc returning requests for the OriginIssuers
+// in the ConfigMap's namespace referencing it for their CA bundle, so rotating the
+// bundle rebuilds their provisioners.
+func (r *OriginIssuerController) IssuersForConfigMap(ctx context.Context, cm client.Object) []reconcile.Request {
+	return r.issuersFor(ctx, cm, ConfigMapIndexField)
+}
 
-	c, err := r.Factory.APIWith(serviceKey)
-	if err != nil {
-		log.Error(err, "failed to create API client")
+// issuersFor returns requests for the OriginIssuers in the object's namespace
+// indexed by its name in field.
+func (r *OriginIssuerController) issuersFor(ctx context.Context, obj client.Object, field string) []reconcile.Request {
+	issuers := v1.OriginIssuerList{}
+	if err := r.Client.List(ctx, &issuers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{field: obj.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list OriginIssuers referencing object", "field", field, "namespace", obj.GetNamespace(), "name", obj.GetName())
 
-		return reconcile.Result{}, err
+		return nil
 	}
 
-	p, err := provisioners.New(c, iss.Spec.RequestType, log)
-	if err != nil {
-		log.Error(err, "failed to create provisioner")
-
-		_ = r.setStatus(ctx, iss, v1.ConditionFalse, "Error", "Failed initialize provisioner")
-
-		return reconcile.Result{}, err
+	requests := make([]reconcile.Request, 0, len(issuers.Items))
+	for _, iss := range issuers.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: iss.Namespace, Name: iss.Name},
+		})
 	}
 
-	// TODO: GC these references once the OriginIssuer has been removed.
-	r.Collection.Store(types.NamespacedName{Name: iss.Name, Namespace: iss.Namespace}, p)
-
-	return reconcile.Result{}, r.setStatus(ctx, iss, v1.ConditionTrue, "Verified", "OriginIssuer verified and ready to sign certificates")
-}
-
-// setStatus is a helper function to set the Issuer status condition with reason and message, and update the API.
-func (r *OriginIssuerController) setStatus(ctx context.Context, iss *v1.OriginIssuer, status v1.ConditionStatus, reason, message string) error {
-	SetIssuerCondition(iss, v1.ConditionReady, status, r.Log, r.Clock, reason, message)
-
-	return r.Client.Status().Update(ctx, iss)
+	return requests
 }
 
-// validateOriginIssuer ensures required fields are set, and enums are correctly set.
-// TODO: move this to another package?
-func validateOriginIssuer(s v1.OriginIssuerSpec) error {
-	switch {
-	case s.Auth.ServiceKeyRef.Name == "":
-		return fmt.Errorf("spec.auth.serviceKeyRef.name cannot be empty")
-	case s.Auth.ServiceKeyRef.Key == "":
-		return fmt.Errorf("spec.auth.serviceKeyRef.key cannot be empty")
-	case s.RequestType == "":
-		return fmt.Errorf("spec.requestType cannot be empty")
-	case s.RequestType != v1.RequestTypeOriginRSA && s.RequestType != v1.RequestTypeOriginECC:
-		return fmt.Errorf("spec.requestType has invalid value %q", s.RequestType)
+func (r *OriginIssuerController) reconciler() *issuerReconciler {
+	return &issuerReconciler{
+		Client:     r.Client,
+		Kind:       "OriginIssuer",
+		Log:        r.Log,
+		Recorder:   r.Recorder,
+		Clock:      r.Clock,
+		Factory:    r.Factory,
+		Collection: r.Collection,
+		Roots:      r.Roots,
+
+		ProbeInterval:  r.ProbeInterval,
+		AllowEndpoints: r.AllowEndpoints,
+		RateLimit:      r.RateLimit,
+		Scope:          r.Scope,
 	}
-
-	return nil
 }
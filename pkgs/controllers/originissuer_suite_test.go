@@ -13,6 +13,7 @@ import (
 
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
 	"github.com/go-logr/zerologr"
@@ -28,7 +29,6 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var cfg *rest.Config
@@ -89,8 +89,8 @@ func TestOriginIssuerReconcileSuite(t *testing.T) {
 	}
 	c := mgr.GetClient()
 
-	f := cfapi.FactoryFunc(func(serviceKey []byte) (cfapi.Interface, error) {
-		return nil, nil
+	f := cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+		return &fakeapi.FakeClient{}, nil
 	})
 
 	controller := &OriginIssuerController{
@@ -98,12 +98,13 @@ func TestOriginIssuerReconcileSuite(t *testing.T) {
 		Clock:      clock.RealClock{},
 		Factory:    f,
 		Log:        logf.Log,
+		Recorder:   mgr.GetEventRecorderFor("origin-ca-issuer"),
 		Collection: provisioners.CollectionWith(nil),
 	}
 
 	builder.ControllerManagedBy(mgr).
 		For(&v1.OriginIssuer{}).
-		Complete(reconcile.AsReconciler(c, controller))
+		Complete(controller)
 
 	cancel, errChan := StartTestManager(mgr, t)
 	defer func() {
@@ -135,7 +136,7 @@ func TestOriginIssuerReconcileSuite(t *testing.T) {
 			return false
 		}
 
-		return IssuerHasCondition(iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue})
+		return IssuerHasCondition(&iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue})
 	}, 5*time.Second, 10*time.Millisecond, "OriginIssuer reconciler")
 
 	_, ok := controller.Collection.Load(types.NamespacedName{
@@ -2,20 +2,28 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	fakeClock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -33,12 +41,66 @@ func TestOriginIssuerReconcile(t *testing.T) {
 	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
 	now := metav1.NewTime(clock.Now())
 
+	ca, err := fakeapi.NewAuthority("ECC", clock.Now())
+	if err != nil {
+		t.Fatalf("creating authority: %s", err)
+	}
+
+	issuerWithEndpoint := &v1.OriginIssuer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: v1.OriginIssuerSpec{
+			RequestType: v1.RequestTypeOriginECC,
+			Auth: v1.OriginIssuerAuthentication{
+				TokenRef: v1.SecretKeySelector{
+					Name: "issuer-api-token",
+					Key:  "token",
+				},
+			},
+			Endpoint: "https://api.staging.example.com",
+		},
+	}
+
+	issuerWithCABundleRef := &v1.OriginIssuer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: v1.OriginIssuerSpec{
+			RequestType: v1.RequestTypeOriginECC,
+			Auth: v1.OriginIssuerAuthentication{
+				TokenRef: v1.SecretKeySelector{
+					Name: "issuer-api-token",
+					Key:  "token",
+				},
+			},
+			CABundleRef: &v1.ConfigMapKeySelector{
+				Name: "origin-ca-roots",
+				Key:  "ca.crt",
+			},
+		},
+	}
+
+	apiToken := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "issuer-api-token",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"token": []byte("api-token"),
+		},
+	}
+
 	tests := []struct {
-		name          string
-		objects       []runtime.Object
-		expected      v1.OriginIssuerStatus
-		error         string
-		namespaceName types.NamespacedName
+		name           string
+		objects        []runtime.Object
+		expected       v1.OriginIssuerStatus
+		error          string
+		namespaceName  types.NamespacedName
+		allowEndpoints bool
+		endpoint       string
 	}{
 		{
 			name: "working with secrets",
@@ -84,6 +146,50 @@ func TestOriginIssuerReconcile(t *testing.T) {
 				Name:      "foo",
 			},
 		},
+		{
+			name: "working with api token",
+			objects: []runtime.Object{
+				&v1.OriginIssuer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.OriginIssuerSpec{
+						RequestType: v1.RequestTypeOriginRSA,
+						Auth: v1.OriginIssuerAuthentication{
+							TokenRef: v1.SecretKeySelector{
+								Name: "issuer-api-token",
+								Key:  "token",
+							},
+						},
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "issuer-api-token",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"token": []byte("api-token"),
+					},
+				},
+			},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionTrue,
+						LastTransitionTime: &now,
+						Reason:             "Verified",
+						Message:            "OriginIssuer verified and ready to sign certificates",
+					},
+				},
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
 		{
 			name: "missing secret",
 			objects: []runtime.Object{
@@ -163,6 +269,175 @@ func TestOriginIssuerReconcile(t *testing.T) {
 				Name:      "foo",
 			},
 		},
+		{
+			name: "working with ca bundle reference",
+			objects: []runtime.Object{
+				issuerWithCABundleRef.DeepCopy(),
+				apiToken.DeepCopy(),
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "origin-ca-roots",
+						Namespace: "default",
+					},
+					Data: map[string]string{
+						"ca.crt": string(ca.PEM()),
+					},
+				},
+			},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionTrue,
+						LastTransitionTime: &now,
+						Reason:             "Verified",
+						Message:            "OriginIssuer verified and ready to sign certificates",
+					},
+				},
+			},
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
+		{
+			name: "missing ca bundle key",
+			objects: []runtime.Object{
+				issuerWithCABundleRef.DeepCopy(),
+				apiToken.DeepCopy(),
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "origin-ca-roots",
+						Namespace: "default",
+					},
+				},
+			},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "NotFound",
+						Message:            `Failed to retrieve CA bundle: configmap origin-ca-roots does not contain key "ca.crt"`,
+					},
+				},
+			},
+			error: `configmap origin-ca-roots does not contain key "ca.crt"`,
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
+		{
+			name: "invalid ca bundle",
+			objects: []runtime.Object{
+				issuerWithCABundleRef.DeepCopy(),
+				apiToken.DeepCopy(),
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "origin-ca-roots",
+						Namespace: "default",
+					},
+					Data: map[string]string{
+						"ca.crt": "bogus",
+					},
+				},
+			},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "InvalidCABundle",
+						Message:            "Failed to load CA bundle: CA bundle does not contain any PEM encoded certificates",
+					},
+				},
+			},
+			error: "CA bundle does not contain any PEM encoded certificates",
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
+		{
+			name:           "working with endpoint",
+			objects:        []runtime.Object{issuerWithEndpoint, apiToken},
+			allowEndpoints: true,
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionTrue,
+						LastTransitionTime: &now,
+						Reason:             "Verified",
+						Message:            "OriginIssuer verified and ready to sign certificates",
+					},
+				},
+			},
+			endpoint: "https://api.staging.example.com",
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
+		{
+			name: "invalid spec",
+			objects: []runtime.Object{
+				&v1.OriginIssuer{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.OriginIssuerSpec{
+						Auth: v1.OriginIssuerAuthentication{
+							TokenRef: v1.SecretKeySelector{
+								Name: "issuer-api-token",
+								Key:  "token",
+							},
+						},
+					},
+				},
+				apiToken,
+			},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "Invalid",
+						Message:            "Issuer is invalid: spec.requestType cannot be empty",
+					},
+				},
+			},
+			error: "spec.requestType cannot be empty",
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
+		{
+			name:    "endpoint not allowed",
+			objects: []runtime.Object{issuerWithEndpoint, apiToken},
+			expected: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{
+						Type:               v1.ConditionReady,
+						Status:             v1.ConditionFalse,
+						LastTransitionTime: &now,
+						Reason:             "EndpointNotAllowed",
+						Message:            "Overriding the Cloudflare API endpoint is not enabled for this controller: endpoint https://api.staging.example.com is not allowed",
+					},
+				},
+			},
+			error: "endpoint https://api.staging.example.com is not allowed",
+			namespaceName: types.NamespacedName{
+				Namespace: "default",
+				Name:      "foo",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -176,17 +451,23 @@ func TestOriginIssuerReconcile(t *testing.T) {
 
 			collection := provisioners.CollectionWith(nil)
 
+			var endpoint string
 			controller := &OriginIssuerController{
 				Client: client,
-				Factory: cfapi.FactoryFunc(func(serviceKey []byte) (cfapi.Interface, error) {
-					return nil, nil
+				Factory: cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+					endpoint = creds.Endpoint
+
+					return &fakeapi.FakeClient{}, nil
 				}),
 				Clock:      clock,
 				Log:        logf.Log,
+				Recorder:   record.NewFakeRecorder(10),
 				Collection: collection,
+
+				AllowEndpoints: tt.allowEndpoints,
 			}
 
-			_, err := reconcile.AsReconciler(client, controller).Reconcile(context.Background(), reconcile.Request{
+			_, err := controller.Reconcile(context.Background(), reconcile.Request{
 				NamespacedName: tt.namespaceName,
 			})
 
@@ -209,6 +490,685 @@ func TestOriginIssuerReconcile(t *testing.T) {
 					t.Fatal("was unable to find provisioner")
 				}
 			}
+
+			if diff := cmp.Diff(endpoint, tt.endpoint); diff != "" {
+				t.Fatalf("endpoint diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOriginIssuerReconcile_RemovesProvisioner(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	deletedAt := metav1.NewTime(clock.Now())
+
+	issuer := func(mods ...func(*v1.OriginIssuer)) *v1.OriginIssuer {
+		iss := &v1.OriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: "default",
+			},
+			Spec: v1.OriginIssuerSpec{
+				RequestType: v1.RequestTypeOriginRSA,
+				Auth: v1.OriginIssuerAuthentication{
+					ServiceKeyRef: v1.SecretKeySelector{
+						Name: "service-key-issuer",
+						Key:  "key",
+					},
+				},
+			},
+		}
+
+		for _, mod := range mods {
+			mod(iss)
+		}
+
+		return iss
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		scope   Scope
+	}{
+		{
+			name: "issuer deleted",
+		},
+		{
+			name: "issuer being deleted",
+			objects: []runtime.Object{
+				issuer(func(iss *v1.OriginIssuer) {
+					iss.DeletionTimestamp = &deletedAt
+					iss.Finalizers = []string{"example.com/finalizer"}
+				}),
+			},
+		},
+		{
+			name: "credentials no longer available",
+			objects: []runtime.Object{
+				issuer(),
+			},
+		},
+		{
+			name: "issuer out of scope",
+			objects: []runtime.Object{
+				issuer(),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "service-key-issuer",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"key": []byte("djEuMC0weDAwQkFCMTBD"),
+					},
+				},
+			},
+			scope: Scope{Namespaces: []string{"other"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(tt.objects...).
+				WithStatusSubresource(&v1.OriginIssuer{}).
+				Build()
+
+			namespaceName := types.NamespacedName{Namespace: "default", Name: "foo"}
+
+			controller := &OriginIssuerController{
+				Client: client,
+				Factory: cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+					return &fakeapi.FakeClient{}, nil
+				}),
+				Clock:    clock,
+				Log:      logf.Log,
+				Recorder: record.NewFakeRecorder(10),
+				Collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+					{
+						NamespacedName: namespaceName,
+						Provisioner:    &provisioners.Provisioner{},
+					},
+				}),
+				Scope: tt.scope,
+			}
+
+			_, _ = controller.Reconcile(context.Background(), reconcile.Request{
+				NamespacedName: namespaceName,
+			})
+
+			if _, ok := controller.Collection.Load(namespaceName); ok {
+				t.Fatal("expected provisioner to be removed")
+			}
+		})
+	}
+}
+
+func TestOriginIssuerReconcile_Probe(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	now := metav1.NewTime(clock.Now())
+
+	tests := []struct {
+		name        string
+		verifyError error
+		expected    v1.OriginIssuerCondition
+		result      reconcile.Result
+	}{
+		{
+			name: "valid credentials",
+			expected: v1.OriginIssuerCondition{
+				Type:               v1.ConditionReady,
+				Status:             v1.ConditionTrue,
+				LastTransitionTime: &now,
+				Reason:             "Verified",
+				Message:            "OriginIssuer verified and ready to sign certificates",
+			},
+			result: reconcile.Result{RequeueAfter: time.Hour},
+		},
+		{
+			name:        "invalid credentials",
+			verifyError: &cfapi.APIError{Code: 10000, Message: "Authentication error", StatusCode: 403},
+			expected: v1.OriginIssuerCondition{
+				Type:               v1.ConditionReady,
+				Status:             v1.ConditionFalse,
+				LastTransitionTime: &now,
+				Reason:             "InvalidCredentials",
+				Message:            "Cloudflare API rejected credentials: Cloudflare API Error code=10000 message=Authentication error ray_id=",
+			},
+		},
+		{
+			name:        "unreachable",
+			verifyError: &cfapi.APIError{Message: "unexpected response with status 503", StatusCode: 503},
+			expected: v1.OriginIssuerCondition{
+				Type:               v1.ConditionReady,
+				Status:             v1.ConditionFalse,
+				LastTransitionTime: &now,
+				Reason:             "Unreachable",
+				Message:            "Failed to verify credentials with the Cloudflare API: Cloudflare API Error code=0 message=unexpected response with status 503 ray_id=",
+			},
+		},
+		{
+			name:        "unexpected error",
+			verifyError: &cfapi.APIError{Code: 1003, Message: "Invalid or missing zone id.", StatusCode: 400},
+			expected: v1.OriginIssuerCondition{
+				Type:               v1.ConditionReady,
+				Status:             v1.ConditionFalse,
+				LastTransitionTime: &now,
+				Reason:             "VerificationFailed",
+				Message:            "Failed to verify credentials with the Cloudflare API: Cloudflare API Error code=1003 message=Invalid or missing zone id. ray_id=",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithObjects(
+					&v1.OriginIssuer{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foo",
+							Namespace: "default",
+						},
+						Spec: v1.OriginIssuerSpec{
+							RequestType: v1.RequestTypeOriginRSA,
+							Auth: v1.OriginIssuerAuthentication{
+								TokenRef: v1.SecretKeySelector{
+									Name: "api-token",
+									Key:  "token",
+								},
+							},
+						},
+					},
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "api-token",
+							Namespace: "default",
+						},
+						Data: map[string][]byte{
+							"token": []byte("api-token"),
+						},
+					},
+				).
+				WithStatusSubresource(&v1.OriginIssuer{}).
+				Build()
+
+			namespaceName := types.NamespacedName{Namespace: "default", Name: "foo"}
+			recorder := record.NewFakeRecorder(10)
+
+			controller := &OriginIssuerController{
+				Client: client,
+				Factory: cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+					return &fakeapi.FakeClient{VerifyError: tt.verifyError}, nil
+				}),
+				Clock:      clock,
+				Log:        logf.Log,
+				Recorder:   recorder,
+				Collection: provisioners.CollectionWith(nil),
+
+				ProbeInterval: time.Hour,
+			}
+
+			result, _ := controller.Reconcile(context.Background(), reconcile.Request{
+				NamespacedName: namespaceName,
+			})
+
+			if diff := cmp.Diff(result, tt.result); diff != "" {
+				t.Fatalf("result diff: (-got +want)\n%s", diff)
+			}
+
+			got := &v1.OriginIssuer{}
+			if err := client.Get(context.TODO(), namespaceName, got); err != nil {
+				t.Fatalf("expected to retrieve issuer from client: %s", err)
+			}
+			if diff := cmp.Diff(got.Status.Conditions, []v1.OriginIssuerCondition{tt.expected}); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+
+			_, ok := controller.Collection.Load(namespaceName)
+			if want := tt.expected.Status == v1.ConditionTrue; ok != want {
+				t.Fatalf("expected provisioner stored %t, got %t", want, ok)
+			}
+
+			eventType := corev1.EventTypeWarning
+			if tt.expected.Status == v1.ConditionTrue {
+				eventType = corev1.EventTypeNormal
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if diff := cmp.Diff(event, fmt.Sprintf("%s %s %s", eventType, tt.expected.Reason, tt.expected.Message)); diff != "" {
+					t.Fatalf("event diff: (-got +want)\n%s", diff)
+				}
+			default:
+				t.Fatal("expected an event to be recorded")
+			}
+
+			// Probing again with the same result does not repeat the event.
+			_, _ = controller.Reconcile(context.Background(), reconcile.Request{
+				NamespacedName: namespaceName,
+			})
+
+			select {
+			case event := <-recorder.Events:
+				t.Fatalf("unexpected event: %s", event)
+			default:
+			}
+		})
+	}
+}
+
+func TestOriginIssuerReconcile_UnreachableKeepsProvisioner(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	now := metav1.NewTime(clock.Now())
+
+	ready := v1.OriginIssuerCondition{
+		Type:               v1.ConditionReady,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: &now,
+		Reason:             "Verified",
+		Message:            "OriginIssuer verified and ready to sign certificates",
+	}
+
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(
+			&v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+				},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeOriginRSA,
+					Auth: v1.OriginIssuerAuthentication{
+						TokenRef: v1.SecretKeySelector{
+							Name: "api-token",
+							Key:  "token",
+						},
+					},
+				},
+				Status: v1.OriginIssuerStatus{
+					Conditions: []v1.OriginIssuerCondition{ready},
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "api-token",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"token": []byte("api-token"),
+				},
+			},
+		).
+		WithStatusSubresource(&v1.OriginIssuer{}).
+		Build()
+
+	namespaceName := types.NamespacedName{Namespace: "default", Name: "foo"}
+	recorder := record.NewFakeRecorder(10)
+	var verifyError error
+
+	controller := &OriginIssuerController{
+		Client: client,
+		Factory: cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+			return &fakeapi.FakeClient{VerifyError: verifyError}, nil
+		}),
+		Clock:      clock,
+		Log:        logf.Log,
+		Recorder:   recorder,
+		Collection: provisioners.CollectionWith(nil),
+
+		ProbeInterval: time.Hour,
+	}
+
+	if _, err := controller.Reconcile(context.Background(), reconcile.Request{NamespacedName: namespaceName}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	existing, ok := controller.Collection.Load(namespaceName)
+	if !ok {
+		t.Fatal("expected a provisioner to be stored")
+	}
+
+	verifyError = &cfapi.APIError{Message: "unexpected response with status 503", StatusCode: 503}
+
+	_, err := controller.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: namespaceName,
+	})
+	if err == nil {
+		t.Fatal("expected error to requeue the probe")
+	}
+
+	if p, ok := controller.Collection.Load(namespaceName); !ok || p != existing {
+		t.Fatal("expected existing provisioner to be kept")
+	}
+
+	got := &v1.OriginIssuer{}
+	if err := client.Get(context.TODO(), namespaceName, got); err != nil {
+		t.Fatalf("expected to retrieve issuer from client: %s", err)
+	}
+	if diff := cmp.Diff(got.Status.Conditions, []v1.OriginIssuerCondition{ready}); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		want := "Warning Unreachable Failed to verify credentials with the Cloudflare API, continuing to use previously verified credentials: Cloudflare API Error code=0 message=unexpected response with status 503 ray_id="
+		if diff := cmp.Diff(event, want); diff != "" {
+			t.Fatalf("event diff: (-got +want)\n%s", diff)
+		}
+	default:
+		t.Fatal("expected an event to be recorded")
+	}
+
+	// Credentials changed since the provisioner was verified must not be used
+	// until they can be verified.
+	secret := &corev1.Secret{}
+	if err := client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "api-token"}, secret); err != nil {
+		t.Fatalf("expected to retrieve secret from client: %s", err)
+	}
+	secret.Data["token"] = []byte("rotated-api-token")
+	if err := client.Update(context.TODO(), secret); err != nil {
+		t.Fatalf("updating secret: %s", err)
+	}
+
+	_, err = controller.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: namespaceName,
+	})
+	if err == nil {
+		t.Fatal("expected error to requeue the probe")
+	}
+
+	if _, ok := controller.Collection.Load(namespaceName); ok {
+		t.Fatal("expected provisioner to be removed")
+	}
+
+	if err := client.Get(context.TODO(), namespaceName, got); err != nil {
+		t.Fatalf("expected to retrieve issuer from client: %s", err)
+	}
+	if diff := cmp.Diff(got.Status.Conditions, []v1.OriginIssuerCondition{
+		{
+			Type:               v1.ConditionReady,
+			Status:             v1.ConditionFalse,
+			LastTransitionTime: &now,
+			Reason:             "Unreachable",
+			Message:            "Failed to verify credentials with the Cloudflare API: Cloudflare API Error code=0 message=unexpected response with status 503 ray_id=",
+		},
+	}); diff != "" {
+		t.Fatalf("diff: (-got +want)\n%s", diff)
+	}
+}
+
+func TestOriginIssuerReconcile_RateLimitPersists(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+
+	iss := &v1.OriginIssuer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: v1.OriginIssuerSpec{
+			RequestType: v1.RequestTypeOriginECC,
+			Auth: v1.OriginIssuerAuthentication{
+				TokenRef: v1.SecretKeySelector{
+					Name: "api-token",
+					Key:  "token",
+				},
+			},
+			RateLimit: &v1.RateLimit{RequestsPerMinute: 1, Burst: 1},
+		},
+	}
+
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(
+			iss,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "api-token",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"token": []byte("api-token"),
+				},
+			},
+		).
+		WithStatusSubresource(&v1.OriginIssuer{}).
+		Build()
+
+	// Signing fails at the API, after the provisioner has taken a token.
+	api := &fakeapi.FakeClient{Error: &cfapi.APIError{Message: "unexpected response with status 500", StatusCode: 500}}
+	namespaceName := types.NamespacedName{Namespace: "default", Name: "foo"}
+
+	controller := &OriginIssuerController{
+		Client: client,
+		Factory: cfapi.FactoryFunc(func(creds cfapi.Credentials) (cfapi.Interface, error) {
+			return api, nil
+		}),
+		Clock:      clock,
+		Log:        logf.Log,
+		Recorder:   record.NewFakeRecorder(10),
+		Collection: provisioners.CollectionWith(nil),
+	}
+
+	cr := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+			if err != nil {
+				t.Fatalf("error creating CSR: %s", err)
+			}
+
+			return csr
+		})()),
+	)
+
+	sign := func() error {
+		t.Helper()
+
+		if _, err := controller.Reconcile(context.Background(), reconcile.Request{NamespacedName: namespaceName}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		p, ok := controller.Collection.Load(namespaceName)
+		if !ok {
+			t.Fatal("expected a provisioner to be stored")
+		}
+
+		_, err := p.Sign(context.Background(), cr)
+
+		return err
+	}
+
+	var rateErr *provisioners.RateLimitError
+	if err := sign(); err == nil || errors.As(err, &rateErr) {
+		t.Fatalf("expected an API error, got %v", err)
+	}
+
+	if err := sign(); !errors.As(err, &rateErr) {
+		t.Fatalf("expected the rate limit to hold across reconciles, got %v", err)
+	}
+	if diff := cmp.Diff(rateErr.RetryAfter, time.Minute); diff != "" {
+		t.Fatalf("retry after diff: (-got +want)\n%s", diff)
+	}
+
+	clock.Step(time.Minute)
+	if err := sign(); err == nil || errors.As(err, &rateErr) {
+		t.Fatalf("expected the rate limit to refill, got %v", err)
+	}
+
+	// Changing the rate limit replaces the exhausted limiter.
+	if err := client.Get(context.Background(), namespaceName, iss); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	iss.Spec.RateLimit.Burst = 2
+	if err := client.Update(context.Background(), iss); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := sign(); err == nil || errors.As(err, &rateErr) {
+		t.Fatalf("expected a new rate limit, got %v", err)
+	}
+}
+
+func TestIssuersForSecret(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := func(name string) v1.OriginIssuerSpec {
+		return v1.OriginIssuerSpec{
+			Auth: v1.OriginIssuerAuthentication{
+				TokenRef: v1.SecretKeySelector{Name: name, Key: "token"},
+			},
+		}
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(
+			&v1.OriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}, Spec: auth("credentials")},
+			&v1.OriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"}, Spec: auth("other")},
+			&v1.OriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "baz", Namespace: "other"}, Spec: auth("credentials")},
+			&v1.ClusterOriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: auth("credentials")},
+		).
+		WithIndex(&v1.OriginIssuer{}, SecretIndexField, IndexIssuerSecret).
+		WithIndex(&v1.ClusterOriginIssuer{}, SecretIndexField, IndexIssuerSecret).
+		Build()
+
+	origin := &OriginIssuerController{Client: c, Log: logf.Log}
+	cluster := &ClusterOriginIssuerController{Client: c, Log: logf.Log, ClusterResourceNamespace: "origin-ca-issuer"}
+
+	secret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: namespace}}
+	}
+
+	tests := []struct {
+		name     string
+		mapFunc  func(context.Context, client.Object) []reconcile.Request
+		secret   *corev1.Secret
+		expected []reconcile.Request
+	}{
+		{
+			name:    "origin issuers in secret namespace",
+			mapFunc: origin.IssuersForSecret,
+			secret:  secret("default"),
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "foo"}},
+			},
+		},
+		{
+			name:    "cluster origin issuers in cluster resource namespace",
+			mapFunc: cluster.IssuersForSecret,
+			secret:  secret("origin-ca-issuer"),
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Name: "foo"}},
+			},
+		},
+		{
+			name:     "cluster origin issuers ignore other namespaces",
+			mapFunc:  cluster.IssuersForSecret,
+			secret:   secret("default"),
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.mapFunc(context.Background(), tt.secret)
+			if diff := cmp.Diff(got, tt.expected, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIssuersForConfigMap(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := func(name string) v1.OriginIssuerSpec {
+		return v1.OriginIssuerSpec{
+			CABundleRef: &v1.ConfigMapKeySelector{Name: name, Key: "ca.crt"},
+		}
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(
+			&v1.OriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}, Spec: bundle("origin-ca-roots")},
+			&v1.OriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"}},
+			&v1.ClusterOriginIssuer{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: bundle("origin-ca-roots")},
+		).
+		WithIndex(&v1.OriginIssuer{}, ConfigMapIndexField, IndexIssuerConfigMap).
+		WithIndex(&v1.ClusterOriginIssuer{}, ConfigMapIndexField, IndexIssuerConfigMap).
+		Build()
+
+	origin := &OriginIssuerController{Client: c, Log: logf.Log}
+	cluster := &ClusterOriginIssuerController{Client: c, Log: logf.Log, ClusterResourceNamespace: "origin-ca-issuer"}
+
+	configMap := func(namespace string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "origin-ca-roots", Namespace: namespace}}
+	}
+
+	tests := []struct {
+		name      string
+		mapFunc   func(context.Context, client.Object) []reconcile.Request
+		configMap *corev1.ConfigMap
+		expected  []reconcile.Request
+	}{
+		{
+			name:      "origin issuers in configmap namespace",
+			mapFunc:   origin.IssuersForConfigMap,
+			configMap: configMap("default"),
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "foo"}},
+			},
+		},
+		{
+			name:      "cluster origin issuers in cluster resource namespace",
+			mapFunc:   cluster.IssuersForConfigMap,
+			configMap: configMap("origin-ca-issuer"),
+			expected: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Name: "foo"}},
+			},
+		},
+		{
+			name:      "cluster origin issuers ignore other namespaces",
+			mapFunc:   cluster.IssuersForConfigMap,
+			configMap: configMap("default"),
+			expected:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.mapFunc(context.Background(), tt.configMap)
+			if diff := cmp.Diff(got, tt.expected, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
 		})
 	}
 }
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ProvisionersReadyCheck is a readiness check that passes once every Ready
+// OriginIssuer and ClusterOriginIssuer has a provisioner in the Collection, so
+// CertificateRequests are not reconciled by a controller that has not yet loaded
+// the issuers they reference. Once the collection has been warmed, the check
+// always passes.
+type ProvisionersReadyCheck struct {
+	Client     client.Reader
+	Collection *provisioners.Collection
+
+	// Elected is closed once the controller is the leader. Until then, the
+	// controllers are not running and the check passes, so standby replicas
+	// are ready.
+	Elected <-chan struct{}
+
+	// Scope restricts the issuers expected to have a provisioner.
+	Scope Scope
+
+	warm atomic.Bool
+}
+
+// Check implements healthz.Checker.
+func (c *ProvisionersReadyCheck) Check(req *http.Request) error {
+	if c.warm.Load() {
+		return nil
+	}
+
+	select {
+	case <-c.Elected:
+	default:
+		return nil
+	}
+
+	var issuers []types.NamespacedName
+
+	originIssuers := &v1.OriginIssuerList{}
+	if err := c.Client.List(req.Context(), originIssuers); err != nil {
+		return fmt.Errorf("failed to list OriginIssuers: %w", err)
+	}
+
+	for i := range originIssuers.Items {
+		iss := &originIssuers.Items[i]
+		if c.Scope.Contains(iss) && IssuerHasCondition(iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue}) {
+			issuers = append(issuers, types.NamespacedName{Namespace: iss.Namespace, Name: iss.Name})
+		}
+	}
+
+	if !c.Scope.DisableClusterOriginIssuers {
+		clusterOriginIssuers := &v1.ClusterOriginIssuerList{}
+		if err := c.Client.List(req.Context(), clusterOriginIssuers); err != nil {
+			return fmt.Errorf("failed to list ClusterOriginIssuers: %w", err)
+		}
+
+		for i := range clusterOriginIssuers.Items {
+			iss := &clusterOriginIssuers.Items[i]
+			if c.Scope.Contains(iss) && IssuerHasCondition(iss, v1.OriginIssuerCondition{Type: v1.ConditionReady, Status: v1.ConditionTrue}) {
+				issuers = append(issuers, types.NamespacedName{Name: iss.Name})
+			}
+		}
+	}
+
+	for _, name := range issuers {
+		if _, ok := c.Collection.Load(name); !ok {
+			return fmt.Errorf("provisioner for issuer %s has not been loaded", issuerLabel(name))
+		}
+	}
+
+	c.warm.Store(true)
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProvisionersReadyCheck(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := v1.OriginIssuerStatus{
+		Conditions: []v1.OriginIssuerCondition{
+			{Type: v1.ConditionReady, Status: v1.ConditionTrue},
+		},
+	}
+	notReady := v1.OriginIssuerStatus{
+		Conditions: []v1.OriginIssuerCondition{
+			{Type: v1.ConditionReady, Status: v1.ConditionFalse},
+		},
+	}
+
+	objects := []runtime.Object{
+		&v1.OriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Status:     ready,
+		},
+		&v1.OriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "default"},
+			Status:     notReady,
+		},
+		&v1.ClusterOriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "bar"},
+			Status:     ready,
+		},
+	}
+
+	elected := make(chan struct{})
+	close(elected)
+
+	tests := []struct {
+		name    string
+		loaded  []types.NamespacedName
+		elected <-chan struct{}
+		error   string
+	}{
+		{
+			name:    "warm",
+			loaded:  []types.NamespacedName{{Namespace: "default", Name: "foo"}, {Name: "bar"}},
+			elected: elected,
+		},
+		{
+			name:    "missing issuer provisioner",
+			loaded:  []types.NamespacedName{{Name: "bar"}},
+			elected: elected,
+			error:   "provisioner for issuer default/foo has not been loaded",
+		},
+		{
+			name:    "missing cluster issuer provisioner",
+			loaded:  []types.NamespacedName{{Namespace: "default", Name: "foo"}},
+			elected: elected,
+			error:   "provisioner for issuer bar has not been loaded",
+		},
+		{
+			name:    "not elected",
+			elected: make(chan struct{}),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(objects...).
+				Build()
+
+			collection := provisioners.CollectionWith(nil)
+			for _, name := range tt.loaded {
+				collection.Store(name, &provisioners.Provisioner{})
+			}
+
+			check := &ProvisionersReadyCheck{
+				Client:     client,
+				Collection: collection,
+				Elected:    tt.elected,
+			}
+
+			var got string
+			if err := check.Check(httptest.NewRequest("GET", "/readyz", nil)); err != nil {
+				got = err.Error()
+			}
+
+			if diff := cmp.Diff(got, tt.error); diff != "" {
+				t.Fatalf("error diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProvisionersReadyCheck_StaysWarm(t *testing.T) {
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithRuntimeObjects(&v1.OriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Status: v1.OriginIssuerStatus{
+				Conditions: []v1.OriginIssuerCondition{
+					{Type: v1.ConditionReady, Status: v1.ConditionTrue},
+				},
+			},
+		}).
+		Build()
+
+	name := types.NamespacedName{Namespace: "default", Name: "foo"}
+	collection := provisioners.CollectionWith([]provisioners.CollectionItem{
+		{NamespacedName: name, Provisioner: &provisioners.Provisioner{}},
+	})
+
+	elected := make(chan struct{})
+	close(elected)
+
+	check := &ProvisionersReadyCheck{
+		Client:     client,
+		Collection: collection,
+		Elected:    elected,
+	}
+
+	if err := check.Check(httptest.NewRequest("GET", "/readyz", nil)); err != nil {
+		t.Fatalf("expected check to pass: %s", err)
+	}
+
+	// Issuers created after the cache has warmed do not fail the check.
+	collection.Delete(name)
+
+	if err := check.Check(httptest.NewRequest("GET", "/readyz", nil)); err != nil {
+		t.Fatalf("expected check to stay passing: %s", err)
+	}
+}
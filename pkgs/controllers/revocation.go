@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RevocationController implements a controller that revokes Origin CA certificates
+// once they have been superseded by a renewal, or their Certificate has been deleted,
+// according to the revocation policy of the issuer that signed them. Certificate IDs
+// are read from the Certificate's CertificateRequests, so certificates of requests
+// cert-manager has already pruned under the Certificate's revisionHistoryLimit are
+// not revoked.
+type RevocationController struct {
+	client.Client
+	Log        logr.Logger
+	Collection *provisioners.Collection
+
+	Clock clock.Clock
+
+	// Scope restricts the Certificates reconciled, and the issuers they may
+	// reference.
+	Scope Scope
+}
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;update
+
+// Reconcile reconciles Certificates by revoking the certificates of any of its
+// CertificateRequests that are no longer in use.
+func (r *RevocationController) Reconcile(ctx context.Context, crt *certmanager.Certificate) (reconcile.Result, error) {
+	log := r.Log.WithValues("namespace", crt.Namespace, "certificate", crt.Name)
+
+	if !r.Scope.Contains(crt) {
+		log.V(4).Info("resource is not within the controller's scope")
+
+		return reconcile.Result{}, nil
+	}
+
+	if _, _, kind := r.Scope.issuerFor(crt.Spec.IssuerRef, crt.Namespace); kind == "" && !controllerutil.ContainsFinalizer(crt, v1.RevocationFinalizer) {
+		log.V(4).Info("resource does not specify an issuerRef that we are responsible for", "group", crt.Spec.IssuerRef.Group, "kind", crt.Spec.IssuerRef.Kind)
+
+		return reconcile.Result{}, nil
+	}
+
+	deleting := !crt.DeletionTimestamp.IsZero()
+
+	if !deleting {
+		policy, err := r.revocationPolicy(ctx, crt)
+		if err != nil {
+			log.Error(err, "failed to retrieve issuer revocation policy")
+
+			return reconcile.Result{}, err
+		}
+
+		wantFinalizer := policy == v1.RevocationPolicyOnDelete
+		if wantFinalizer != controllerutil.ContainsFinalizer(crt, v1.RevocationFinalizer) {
+			if wantFinalizer {
+				controllerutil.AddFinalizer(crt, v1.RevocationFinalizer)
+			} else {
+				controllerutil.RemoveFinalizer(crt, v1.RevocationFinalizer)
+			}
+
+			if err := r.Client.Update(ctx, crt); err != nil {
+				log.Error(err, "failed to update revocation finalizer")
+
+				return reconcile.Result{}, err
+			}
+		}
+	} else if !controllerutil.ContainsFinalizer(crt, v1.RevocationFinalizer) {
+		return reconcile.Result{}, nil
+	}
+
+	crs := certmanager.CertificateRequestList{}
+	if err := r.Client.List(ctx, &crs, client.InNamespace(crt.Namespace)); err != nil {
+		log.Error(err, "failed to list CertificateRequests")
+
+		return reconcile.Result{}, err
+	}
+
+	var errs []error
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+
+		if !metav1.IsControlledBy(cr, crt) || !r.shouldRevoke(crt, cr, deleting) {
+			continue
+		}
+
+		if err := r.revoke(ctx, cr, deleting, log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if deleting {
+		controllerutil.RemoveFinalizer(crt, v1.RevocationFinalizer)
+		if err := r.Client.Update(ctx, crt); err != nil {
+			log.Error(err, "failed to remove revocation finalizer")
+
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// shouldRevoke returns true if the CertificateRequest has a certificate that has not
+// been revoked, and is either superseded or the Certificate is being deleted.
+func (r *RevocationController) shouldRevoke(crt *certmanager.Certificate, cr *certmanager.CertificateRequest, deleting bool) bool {
+	if cr.Annotations[v1.CertificateIDAnnotationKey] == "" || cr.Annotations[v1.RevokedAtAnnotationKey] != "" {
+		return false
+	}
+
+	if deleting {
+		return true
+	}
+
+	if crt.Status.Revision == nil {
+		return false
+	}
+
+	revision, err := strconv.Atoi(cr.Annotations[certmanager.CertificateRequestRevisionAnnotationKey])
+	if err != nil {
+		return false
+	}
+
+	return revision < *crt.Status.Revision
+}
+
+// revoke revokes the certificate of the CertificateRequest if permitted by the
+// revocation policy of its issuer, and records the revocation on the CertificateRequest.
+func (r *RevocationController) revoke(ctx context.Context, cr *certmanager.CertificateRequest, deleting bool, log logr.Logger) error {
+	iss, issNamespaceName, kind := r.Scope.issuerFor(cr.Spec.IssuerRef, cr.Namespace)
+	if kind == "" {
+		return nil
+	}
+
+	if err := r.Client.Get(ctx, issNamespaceName, iss); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("issuer no longer exists, unable to revoke certificate", "kind", kind, "issuer", issNamespaceName, "certificaterequest", cr.Name)
+
+			return nil
+		}
+
+		return err
+	}
+
+	if !permitsRevocation(iss.GetSpec().RevocationPolicy, deleting) {
+		return nil
+	}
+
+	p, ok := r.Collection.Load(issNamespaceName)
+	if !ok {
+		return fmt.Errorf("provisioner %s not found", issNamespaceName)
+	}
+
+	id := cr.Annotations[v1.CertificateIDAnnotationKey]
+	if err := p.Revoke(ctx, id); err != nil {
+		log.Error(err, "failed to revoke certificate", "certificaterequest", cr.Name, "id", id)
+
+		return err
+	}
+
+	log.Info("revoked certificate", "certificaterequest", cr.Name, "id", id)
+
+	metav1.SetMetaDataAnnotation(&cr.ObjectMeta, v1.RevokedAtAnnotationKey, r.Clock.Now().UTC().Format(time.RFC3339))
+
+	return r.Client.Update(ctx, cr)
+}
+
+// revocationPolicy returns the revocation policy of the Certificate's issuer, or
+// Never if the issuer does not exist.
+func (r *RevocationController) revocationPolicy(ctx context.Context, crt *certmanager.Certificate) (v1.RevocationPolicy, error) {
+	iss, issNamespaceName, kind := r.Scope.issuerFor(crt.Spec.IssuerRef, crt.Namespace)
+	if kind == "" {
+		return v1.RevocationPolicyNever, nil
+	}
+
+	if err := r.Client.Get(ctx, issNamespaceName, iss); err != nil {
+		if apierrors.IsNotFound(err) {
+			return v1.RevocationPolicyNever, nil
+		}
+
+		return "", err
+	}
+
+	return iss.GetSpec().RevocationPolicy, nil
+}
+
+// permitsRevocation returns true if the policy allows revoking a superseded
+// certificate, or the current certificate of a deleted Certificate.
+func permitsRevocation(policy v1.RevocationPolicy, deleting bool) bool {
+	switch policy {
+	case v1.RevocationPolicyOnDelete:
+		return true
+	case v1.RevocationPolicyOnRenewal:
+		return !deleting
+	}
+
+	return false
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/cloudflare/origin-ca-issuer/pkgs/provisioners"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeClock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRevocationReconcile(t *testing.T) {
+	if err := cmapi.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+	revokedAt := clock.Now().UTC().Format(time.RFC3339)
+	deletedAt := metav1.NewTime(clock.Now())
+
+	issuer := func(policy v1.RevocationPolicy) *v1.OriginIssuer {
+		return &v1.OriginIssuer{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foobar",
+				Namespace: "default",
+			},
+			Spec: v1.OriginIssuerSpec{
+				RequestType:      v1.RequestTypeOriginECC,
+				RevocationPolicy: policy,
+			},
+		}
+	}
+
+	certificate := func(mods ...func(*cmapi.Certificate)) *cmapi.Certificate {
+		crt := &cmapi.Certificate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foobar",
+				Namespace: "default",
+				UID:       "certificate-uid",
+			},
+			Spec: cmapi.CertificateSpec{
+				IssuerRef: cmmeta.ObjectReference{
+					Name:  "foobar",
+					Kind:  "OriginIssuer",
+					Group: "cert-manager.k8s.cloudflare.com",
+				},
+			},
+			Status: cmapi.CertificateStatus{
+				Revision: ptr.To(2),
+			},
+		}
+
+		for _, mod := range mods {
+			mod(crt)
+		}
+
+		return crt
+	}
+
+	request := func(name, revision, id string) *cmapi.CertificateRequest {
+		return &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					cmapi.CertificateRequestRevisionAnnotationKey: revision,
+					v1.CertificateIDAnnotationKey:                 id,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "cert-manager.io/v1",
+						Kind:       "Certificate",
+						Name:       "foobar",
+						UID:        "certificate-uid",
+						Controller: ptr.To(true),
+					},
+				},
+			},
+			Spec: cmapi.CertificateRequestSpec{
+				IssuerRef: cmmeta.ObjectReference{
+					Name:  "foobar",
+					Kind:  "OriginIssuer",
+					Group: "cert-manager.k8s.cloudflare.com",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		objects   []client.Object
+		revoked   []string
+		finalizer bool
+		deleted   bool
+	}{
+		{
+			name: "revokes superseded certificates on renewal",
+			objects: []client.Object{
+				issuer(v1.RevocationPolicyOnRenewal),
+				certificate(),
+				request("foobar-1", "1", "1"),
+				request("foobar-2", "2", "2"),
+			},
+			revoked: []string{"1"},
+		},
+		{
+			// Certificate IDs are only recorded on CertificateRequests, so once
+			// cert-manager prunes a superseded request its certificate cannot be
+			// revoked.
+			name: "does not revoke certificates of pruned requests",
+			objects: []client.Object{
+				issuer(v1.RevocationPolicyOnRenewal),
+				certificate(func(crt *cmapi.Certificate) {
+					crt.Status.Revision = ptr.To(3)
+				}),
+				request("foobar-3", "3", "3"),
+			},
+		},
+		{
+			name: "never revokes",
+			objects: []client.Object{
+				issuer(v1.RevocationPolicyNever),
+				certificate(),
+				request("foobar-1", "1", "1"),
+				request("foobar-2", "2", "2"),
+			},
+		},
+		{
+			name: "adds finalizer when revoking on delete",
+			objects: []client.Object{
+				issuer(v1.RevocationPolicyOnDelete),
+				certificate(),
+				request("foobar-1", "1", "1"),
+				request("foobar-2", "2", "2"),
+			},
+			revoked:   []string{"1"},
+			finalizer: true,
+		},
+		{
+			name: "revokes all certificates on delete",
+			objects: []client.Object{
+				issuer(v1.RevocationPolicyOnDelete),
+				certificate(func(crt *cmapi.Certificate) {
+					crt.DeletionTimestamp = &deletedAt
+					crt.Finalizers = []string{v1.RevocationFinalizer}
+				}),
+				request("foobar-1", "1", "1"),
+				request("foobar-2", "2", "2"),
+			},
+			revoked: []string{"1", "2"},
+			deleted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithObjects(tt.objects...).
+				Build()
+
+			signer := &fakeapi.FakeClient{}
+			p, err := provisioners.New(signer, v1.RequestTypeOriginECC, logf.Log)
+			if err != nil {
+				t.Fatalf("error creating provisioner: %s", err)
+			}
+
+			controller := &RevocationController{
+				Client: client,
+				Log:    logf.Log,
+				Collection: provisioners.CollectionWith([]provisioners.CollectionItem{
+					{
+						NamespacedName: types.NamespacedName{Namespace: "default", Name: "foobar"},
+						Provisioner:    p,
+					},
+				}),
+				Clock: clock,
+			}
+
+			namespaceName := types.NamespacedName{Namespace: "default", Name: "foobar"}
+			_, err = reconcile.AsReconciler(client, controller).Reconcile(context.Background(), reconcile.Request{
+				NamespacedName: namespaceName,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(signer.Revoked, tt.revoked); diff != "" {
+				t.Fatalf("revoked diff: (-got +want)\n%s", diff)
+			}
+
+			for _, id := range tt.revoked {
+				cr := &cmapi.CertificateRequest{}
+				if err := client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "foobar-" + id}, cr); err != nil {
+					t.Fatalf("expected to retrieve certificate request: %s", err)
+				}
+
+				if diff := cmp.Diff(cr.Annotations[v1.RevokedAtAnnotationKey], revokedAt); diff != "" {
+					t.Fatalf("revoked-at diff: (-got +want)\n%s", diff)
+				}
+			}
+
+			crt := &cmapi.Certificate{}
+			err = client.Get(context.TODO(), namespaceName, crt)
+			if tt.deleted {
+				if err == nil {
+					t.Fatal("expected certificate to be deleted once finalizer was removed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected to retrieve certificate: %s", err)
+			}
+
+			if got := controllerutil.ContainsFinalizer(crt, v1.RevocationFinalizer); got != tt.finalizer {
+				t.Fatalf("expected finalizer %t, got %t", tt.finalizer, got)
+			}
+		})
+	}
+}
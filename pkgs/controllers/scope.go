@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"slices"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Scope restricts the resources the controllers reconcile to a set of namespaces
+// and labels. The zero value reconciles all resources.
+type Scope struct {
+	// Namespaces are the namespaces resources are reconciled in. If empty,
+	// resources in all namespaces are reconciled.
+	Namespaces []string
+
+	// IssuerSelector selects the OriginIssuers and ClusterOriginIssuers that
+	// are reconciled. If nil, all issuers are reconciled.
+	IssuerSelector labels.Selector
+
+	// CertificateRequestSelector selects the CertificateRequests and Certificates
+	// that are reconciled. If nil, all CertificateRequests and Certificates are
+	// reconciled.
+	CertificateRequestSelector labels.Selector
+
+	// DisableClusterOriginIssuers ignores ClusterOriginIssuers, and resources
+	// referencing them, as they cannot be watched without cluster-wide permissions.
+	DisableClusterOriginIssuers bool
+}
+
+// Contains returns true if the object is within the scope.
+func (s Scope) Contains(obj client.Object) bool {
+	if ns := obj.GetNamespace(); ns != "" && len(s.Namespaces) > 0 && !slices.Contains(s.Namespaces, ns) {
+		return false
+	}
+
+	var selector labels.Selector
+	switch obj.(type) {
+	case *v1.OriginIssuer:
+		selector = s.IssuerSelector
+	case *v1.ClusterOriginIssuer:
+		if s.DisableClusterOriginIssuers {
+			return false
+		}
+
+		selector = s.IssuerSelector
+	case *certmanager.CertificateRequest, *certmanager.Certificate:
+		selector = s.CertificateRequestSelector
+	}
+
+	return selector == nil || selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// CacheOptions returns options restricting the manager's cache to the scope, so
+// the controller only needs permissions to watch resources within it. Secrets and
+// ConfigMaps referenced by ClusterOriginIssuers are also watched in the
+// clusterResourceNamespace, unless ClusterOriginIssuers are disabled.
+func (s Scope) CacheOptions(clusterResourceNamespace string) cache.Options {
+	opts := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&v1.OriginIssuer{}:                {Label: s.IssuerSelector},
+			&certmanager.CertificateRequest{}: {Label: s.CertificateRequestSelector},
+			&certmanager.Certificate{}:        {Label: s.CertificateRequestSelector},
+		},
+	}
+
+	if !s.DisableClusterOriginIssuers {
+		opts.ByObject[&v1.ClusterOriginIssuer{}] = cache.ByObject{Label: s.IssuerSelector}
+	}
+
+	if len(s.Namespaces) == 0 {
+		return opts
+	}
+
+	opts.DefaultNamespaces = make(map[string]cache.Config, len(s.Namespaces))
+	for _, ns := range s.Namespaces {
+		opts.DefaultNamespaces[ns] = cache.Config{}
+	}
+
+	if !s.DisableClusterOriginIssuers {
+		namespaces := make(map[string]cache.Config, len(s.Namespaces)+1)
+		for ns := range opts.DefaultNamespaces {
+			namespaces[ns] = cache.Config{}
+		}
+		namespaces[clusterResourceNamespace] = cache.Config{}
+
+		opts.ByObject[&core.Secret{}] = cache.ByObject{Namespaces: namespaces}
+		opts.ByObject[&core.ConfigMap{}] = cache.ByObject{Namespaces: namespaces}
+	}
+
+	return opts
+}
+
+// issuerFor is like the package level issuerFor, but treats references to
+// ClusterOriginIssuers as references to another issuer if they are disabled.
+func (s Scope) issuerFor(ref cmmeta.ObjectReference, namespace string) (v1.GenericIssuer, types.NamespacedName, string) {
+	iss, name, kind := issuerFor(ref, namespace)
+	if kind == "ClusterOriginIssuer" && s.DisableClusterOriginIssuers {
+		return nil, types.NamespacedName{}, ""
+	}
+
+	return iss, name, kind
+}
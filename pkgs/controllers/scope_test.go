@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/google/go-cmp/cmp"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestScopeContains(t *testing.T) {
+	meta := func(namespace string, l map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: "foo", Namespace: namespace, Labels: l}
+	}
+
+	scope := Scope{
+		Namespaces:                 []string{"default", "team"},
+		IssuerSelector:             labels.SelectorFromSet(labels.Set{"issuer": "origin"}),
+		CertificateRequestSelector: labels.SelectorFromSet(labels.Set{"team": "edge"}),
+	}
+
+	tests := []struct {
+		name     string
+		scope    Scope
+		obj      client.Object
+		expected bool
+	}{
+		{
+			name:     "zero value",
+			obj:      &v1.OriginIssuer{ObjectMeta: meta("default", nil)},
+			expected: true,
+		},
+		{
+			name:     "issuer matching labels",
+			scope:    scope,
+			obj:      &v1.OriginIssuer{ObjectMeta: meta("team", map[string]string{"issuer": "origin"})},
+			expected: true,
+		},
+		{
+			name:  "issuer not matching labels",
+			scope: scope,
+			obj:   &v1.OriginIssuer{ObjectMeta: meta("team", map[string]string{"team": "edge"})},
+		},
+		{
+			name:  "issuer in other namespace",
+			scope: scope,
+			obj:   &v1.OriginIssuer{ObjectMeta: meta("other", map[string]string{"issuer": "origin"})},
+		},
+		{
+			name:     "cluster issuer",
+			scope:    scope,
+			obj:      &v1.ClusterOriginIssuer{ObjectMeta: meta("", map[string]string{"issuer": "origin"})},
+			expected: true,
+		},
+		{
+			name: "cluster issuer disabled",
+			scope: Scope{
+				DisableClusterOriginIssuers: true,
+			},
+			obj: &v1.ClusterOriginIssuer{ObjectMeta: meta("", nil)},
+		},
+		{
+			name:     "certificate request matching labels",
+			scope:    scope,
+			obj:      &certmanager.CertificateRequest{ObjectMeta: meta("default", map[string]string{"team": "edge"})},
+			expected: true,
+		},
+		{
+			name:  "certificate not matching labels",
+			scope: scope,
+			obj:   &certmanager.Certificate{ObjectMeta: meta("default", map[string]string{"issuer": "origin"})},
+		},
+		{
+			name:     "other object",
+			scope:    scope,
+			obj:      &core.Secret{ObjectMeta: meta("default", nil)},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.scope.Contains(tt.obj), tt.expected); diff != "" {
+				t.Fatalf("diff: (-got +want)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScopeIssuerFor(t *testing.T) {
+	ref := cmmeta.ObjectReference{Name: "foo", Kind: "ClusterOriginIssuer", Group: v1.GroupVersion.Group}
+
+	if _, _, kind := (Scope{}).issuerFor(ref, "default"); kind != "ClusterOriginIssuer" {
+		t.Fatalf("expected ClusterOriginIssuer kind, got %q", kind)
+	}
+
+	if _, _, kind := (Scope{DisableClusterOriginIssuers: true}).issuerFor(ref, "default"); kind != "" {
+		t.Fatalf("expected empty kind, got %q", kind)
+	}
+}
+
+func TestScopeCacheOptions(t *testing.T) {
+	// byObject returns the sorted namespaces configured for objects of the same
+	// type as obj, and whether the type is configured at all.
+	byObject := func(opts cache.Options, obj client.Object) ([]string, bool) {
+		for o, config := range opts.ByObject {
+			if reflect.TypeOf(o) == reflect.TypeOf(obj) {
+				return sortedKeys(config.Namespaces), true
+			}
+		}
+
+		return nil, false
+	}
+
+	tests := []struct {
+		name           string
+		scope          Scope
+		namespaces     []string
+		secrets        []string
+		clusterIssuers bool
+	}{
+		{
+			name:           "all namespaces",
+			clusterIssuers: true,
+		},
+		{
+			name:           "watched namespaces",
+			scope:          Scope{Namespaces: []string{"team", "default"}},
+			namespaces:     []string{"default", "team"},
+			secrets:        []string{"default", "origin-ca-issuer", "team"},
+			clusterIssuers: true,
+		},
+		{
+			name:       "watched namespaces without cluster issuers",
+			scope:      Scope{Namespaces: []string{"default"}, DisableClusterOriginIssuers: true},
+			namespaces: []string{"default"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.scope.CacheOptions("origin-ca-issuer")
+
+			if diff := cmp.Diff(sortedKeys(opts.DefaultNamespaces), tt.namespaces); diff != "" {
+				t.Fatalf("namespaces diff: (-got +want)\n%s", diff)
+			}
+
+			if secrets, _ := byObject(opts, &core.Secret{}); !cmp.Equal(secrets, tt.secrets) {
+				t.Fatalf("secret namespaces diff: (-got +want)\n%s", cmp.Diff(secrets, tt.secrets))
+			}
+
+			if configMaps, _ := byObject(opts, &core.ConfigMap{}); !cmp.Equal(configMaps, tt.secrets) {
+				t.Fatalf("configmap namespaces diff: (-got +want)\n%s", cmp.Diff(configMaps, tt.secrets))
+			}
+
+			if _, ok := byObject(opts, &v1.ClusterOriginIssuer{}); ok != tt.clusterIssuers {
+				t.Fatalf("expected ClusterOriginIssuers cached %t, got %t", tt.clusterIssuers, ok)
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]cache.Config) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
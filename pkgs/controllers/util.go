@@ -1,18 +1,20 @@
 package controllers
 
 import (
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/go-logr/logr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/clock"
 )
 
-// IssuerHasCondition will return true if the given OriginIssuer has a condition
-// matching the provided OriginIssuerCondtion. Only the Type and Status fields
+// IssuerHasCondition will return true if the given OriginIssuer or ClusterOriginIssuer
+// has a condition matching the provided OriginIssuerCondtion. Only the Type and Status fields
 // are used in the comparison, meaning this function will return `true` even if
 // the Reason, Message, and LastTransitionTime fields do not match.
-func IssuerHasCondition(iss v1.OriginIssuer, c v1.OriginIssuerCondition) bool {
-	for _, cond := range iss.Status.Conditions {
+func IssuerHasCondition(iss v1.GenericIssuer, c v1.OriginIssuerCondition) bool {
+	for _, cond := range iss.GetStatus().Conditions {
 		if c.Type == cond.Type && c.Status == cond.Status {
 			return true
 		}
@@ -21,7 +23,7 @@ func IssuerHasCondition(iss v1.OriginIssuer, c v1.OriginIssuerCondition) bool {
 	return false
 }
 
-// SetIssuerCondition will set a condition on the given OriginIssuer.
+// SetIssuerCondition will set a condition on the given OriginIssuer or ClusterOriginIssuer.
 //
 // If no condition of the same type exists, the condition will be inserted with
 // the LastTransitionTime set to the current time.
@@ -32,7 +34,7 @@ func IssuerHasCondition(iss v1.OriginIssuer, c v1.OriginIssuerCondition) bool {
 // If a condition of the same type and different state already exists, the
 // condition will be updated and the LastTransitionTime set to the current
 // time.
-func SetIssuerCondition(iss *v1.OriginIssuer, conditionType v1.ConditionType, status v1.ConditionStatus, log logr.Logger, cl clock.Clock, reason, message string) {
+func SetIssuerCondition(iss v1.GenericIssuer, conditionType v1.ConditionType, status v1.ConditionStatus, log logr.Logger, cl clock.Clock, reason, message string) {
 	now := metav1.NewTime(cl.Now())
 	c := v1.OriginIssuerCondition{
 		Type:               conditionType,
@@ -42,7 +44,8 @@ func SetIssuerCondition(iss *v1.OriginIssuer, conditionType v1.ConditionType, st
 		LastTransitionTime: &now,
 	}
 
-	for i, condition := range iss.Status.Conditions {
+	st := iss.GetStatus()
+	for i, condition := range st.Conditions {
 		if condition.Type != conditionType {
 			continue
 		}
@@ -50,17 +53,46 @@ func SetIssuerCondition(iss *v1.OriginIssuer, conditionType v1.ConditionType, st
 		if condition.Status == status {
 			c.LastTransitionTime = condition.LastTransitionTime
 		} else {
-			log.Info("found status change for OriginIssuer; setting lastTransitionTime",
+			log.Info("found status change for issuer; setting lastTransitionTime",
 				"condition", condition.Type,
 				"old_status", condition.Status,
 				"new_status", c.Status,
 			)
 		}
 
-		iss.Status.Conditions[i] = c
+		st.Conditions[i] = c
 
 		return
 	}
 
-	iss.Status.Conditions = append(iss.Status.Conditions, c)
+	st.Conditions = append(st.Conditions, c)
+}
+
+// issuerFor returns an empty issuer of the kind referenced by ref, the key used to
+// retrieve the issuer and its provisioner, and the kind of the issuer. If ref does
+// not reference an issuer this controller is responsible for, the returned kind is
+// empty.
+func issuerFor(ref cmmeta.ObjectReference, namespace string) (v1.GenericIssuer, types.NamespacedName, string) {
+	if ref.Group != "" && ref.Group != v1.GroupVersion.Group {
+		return nil, types.NamespacedName{}, ""
+	}
+
+	switch ref.Kind {
+	case "", "OriginIssuer":
+		return &v1.OriginIssuer{}, types.NamespacedName{Namespace: namespace, Name: ref.Name}, "OriginIssuer"
+	case "ClusterOriginIssuer":
+		return &v1.ClusterOriginIssuer{}, types.NamespacedName{Name: ref.Name}, "ClusterOriginIssuer"
+	}
+
+	return nil, types.NamespacedName{}, ""
+}
+
+// issuerLabel returns the name of an issuer for use as a metric label, omitting
+// the namespace of cluster scoped issuers.
+func issuerLabel(name types.NamespacedName) string {
+	if name.Namespace == "" {
+		return name.Name
+	}
+
+	return name.String()
 }
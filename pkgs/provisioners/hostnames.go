@@ -0,0 +1,70 @@
+package provisioners
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CSRError is returned when a CertificateRequest's CSR requests names the
+// Cloudflare API cannot issue a certificate for.
+type CSRError struct {
+	// Reason describes why the CSR cannot be signed.
+	Reason string
+}
+
+func (e *CSRError) Error() string {
+	return e.Reason
+}
+
+// hostnamesFor returns the hostnames to request a certificate for: the CSR's DNS
+// names, and its common name if it is not already one of them. Hostnames are
+// lowercased, and duplicates removed. CSRs with IP address, URI, or email address
+// SANs fail with a CSRError, as the Cloudflare API only issues certificates for
+// hostnames.
+func hostnamesFor(csr *x509.CertificateRequest) ([]string, error) {
+	if len(csr.IPAddresses) > 0 {
+		return nil, &CSRError{Reason: fmt.Sprintf("IP address SANs are not supported: %v", csr.IPAddresses)}
+	}
+
+	if len(csr.URIs) > 0 {
+		uris := make([]string, 0, len(csr.URIs))
+		for _, u := range csr.URIs {
+			uris = append(uris, u.String())
+		}
+
+		return nil, &CSRError{Reason: fmt.Sprintf("URI SANs are not supported: %v", uris)}
+	}
+
+	if len(csr.EmailAddresses) > 0 {
+		return nil, &CSRError{Reason: fmt.Sprintf("email address SANs are not supported: %v", csr.EmailAddresses)}
+	}
+
+	names := csr.DNSNames
+	if cn := csr.Subject.CommonName; cn != "" {
+		if net.ParseIP(cn) != nil {
+			return nil, &CSRError{Reason: fmt.Sprintf("common name %q is an IP address, which is not supported", cn)}
+		}
+
+		names = append([]string{cn}, names...)
+	}
+
+	seen := make(map[string]bool, len(names))
+	hostnames := make([]string, 0, len(names))
+	for _, name := range names {
+		hostname := strings.TrimSuffix(strings.ToLower(name), ".")
+		if hostname == "" || seen[hostname] {
+			continue
+		}
+
+		seen[hostname] = true
+		hostnames = append(hostnames, hostname)
+	}
+
+	if len(hostnames) == 0 {
+		return nil, &CSRError{Reason: "CSR does not contain any DNS names or a common name"}
+	}
+
+	return hostnames, nil
+}
@@ -0,0 +1,148 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+)
+
+func TestHostnamesFor(t *testing.T) {
+	type testCase struct {
+		name     string
+		csr      *x509.CertificateRequest
+		expected []string
+		error    string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		hostnames, err := hostnamesFor(tc.csr)
+		if tc.error != "" {
+			assert.Error(t, err, tc.error)
+
+			var csrErr *CSRError
+			assert.Assert(t, errors.As(err, &csrErr))
+			return
+		}
+
+		assert.NilError(t, err)
+		assert.DeepEqual(t, hostnames, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "dns names",
+			csr:      &x509.CertificateRequest{DNSNames: []string{"example.com", "www.example.com"}},
+			expected: []string{"example.com", "www.example.com"},
+		},
+		{
+			name: "common name only",
+			csr: &x509.CertificateRequest{
+				Subject: pkix.Name{CommonName: "example.com"},
+			},
+			expected: []string{"example.com"},
+		},
+		{
+			name: "common name merged",
+			csr: &x509.CertificateRequest{
+				Subject:  pkix.Name{CommonName: "example.com"},
+				DNSNames: []string{"www.example.com"},
+			},
+			expected: []string{"example.com", "www.example.com"},
+		},
+		{
+			name: "common name already a dns name",
+			csr: &x509.CertificateRequest{
+				Subject:  pkix.Name{CommonName: "Example.com"},
+				DNSNames: []string{"www.example.com", "example.com"},
+			},
+			expected: []string{"example.com", "www.example.com"},
+		},
+		{
+			name:     "normalized and deduplicated",
+			csr:      &x509.CertificateRequest{DNSNames: []string{"WWW.Example.com", "www.example.com.", "*.Example.com"}},
+			expected: []string{"www.example.com", "*.example.com"},
+		},
+		{
+			name:  "no names",
+			csr:   &x509.CertificateRequest{},
+			error: "CSR does not contain any DNS names or a common name",
+		},
+		{
+			name: "ip address san",
+			csr: &x509.CertificateRequest{
+				DNSNames:    []string{"example.com"},
+				IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+			},
+			error: "IP address SANs are not supported: [192.0.2.1]",
+		},
+		{
+			name: "uri san",
+			csr: &x509.CertificateRequest{
+				DNSNames: []string{"example.com"},
+				URIs:     []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/foo"}},
+			},
+			error: "URI SANs are not supported: [spiffe://example.com/foo]",
+		},
+		{
+			name: "email address san",
+			csr: &x509.CertificateRequest{
+				DNSNames:       []string{"example.com"},
+				EmailAddresses: []string{"admin@example.com"},
+			},
+			error: "email address SANs are not supported: [admin@example.com]",
+		},
+		{
+			name: "ip address common name",
+			csr: &x509.CertificateRequest{
+				Subject: pkix.Name{CommonName: "192.0.2.1"},
+			},
+			error: `common name "192.0.2.1" is an IP address, which is not supported`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSign_CommonName(t *testing.T) {
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		assert.DeepEqual(t, req.Hostnames, []string{"example.com", "www.example.com"})
+
+		return signResponse(ca, req, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRCommonName("Example.com"), cmgen.SetCSRDNSNames("www.example.com", "example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	provisioner, err := New(signer, v1.RequestTypeOriginECC, logr.Discard())
+	assert.NilError(t, err)
+
+	_, err = provisioner.Sign(context.Background(), req)
+	assert.NilError(t, err)
+}
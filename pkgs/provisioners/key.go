@@ -0,0 +1,77 @@
+package provisioners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+)
+
+// minRSAKeySize is the smallest RSA key, in bits, accepted by the Cloudflare API.
+const minRSAKeySize = 2048
+
+// KeyError is returned when the public key of a CertificateRequest's CSR cannot
+// be signed with its issuer's request type.
+type KeyError struct {
+	// RequestType is the request type of the issuer.
+	RequestType v1.RequestType
+
+	// Reason describes why the key cannot be signed.
+	Reason string
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("CSR cannot be signed with request type %s: %s", e.RequestType, e.Reason)
+}
+
+// requestTypeFor checks the CSR's public key can be signed with the request type,
+// returning the request type to sign it with. The Auto request type resolves to
+// OriginRSA or OriginECC from the key's algorithm.
+func requestTypeFor(reqType v1.RequestType, csr *x509.CertificateRequest) (v1.RequestType, error) {
+	var keyType v1.RequestType
+
+	switch key := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < minRSAKeySize {
+			return "", &KeyError{RequestType: reqType, Reason: fmt.Sprintf("RSA key size %d is smaller than %d bits", size, minRSAKeySize)}
+		}
+
+		keyType = v1.RequestTypeOriginRSA
+	case *ecdsa.PublicKey:
+		if curve := key.Curve; curve != elliptic.P256() && curve != elliptic.P384() {
+			return "", &KeyError{RequestType: reqType, Reason: fmt.Sprintf("ECDSA curve %s is not supported, use P-256 or P-384", curve.Params().Name)}
+		}
+
+		keyType = v1.RequestTypeOriginECC
+	default:
+		return "", &KeyError{RequestType: reqType, Reason: fmt.Sprintf("%s keys are not supported, use RSA or ECDSA", csr.PublicKeyAlgorithm)}
+	}
+
+	if reqType != v1.RequestTypeAuto && reqType != keyType {
+		return "", &KeyError{RequestType: reqType, Reason: fmt.Sprintf("%s key requires request type %s", csr.PublicKeyAlgorithm, keyType)}
+	}
+
+	return keyType, nil
+}
+
+// ResolveRequestType returns the request type an issuer with reqType signs the
+// CertificateRequest with, resolving Auto from the CSR's key. reqType is returned
+// if the CSR cannot be decoded or signed with it.
+func ResolveRequestType(reqType v1.RequestType, cr *certmanager.CertificateRequest) v1.RequestType {
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
+	if err != nil {
+		return reqType
+	}
+
+	resolved, err := requestTypeFor(reqType, csr)
+	if err != nil {
+		return reqType
+	}
+
+	return resolved
+}
@@ -0,0 +1,130 @@
+package provisioners
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestRequestTypeFor(t *testing.T) {
+	type testCase struct {
+		name     string
+		reqType  v1.RequestType
+		key      func() (crypto.Signer, error)
+		expected v1.RequestType
+		error    string
+	}
+
+	rsaKey := func(bits int) func() (crypto.Signer, error) {
+		return func() (crypto.Signer, error) {
+			return rsa.GenerateKey(rand.Reader, bits)
+		}
+	}
+
+	ecdsaKey := func(curve elliptic.Curve) func() (crypto.Signer, error) {
+		return func() (crypto.Signer, error) {
+			return ecdsa.GenerateKey(curve, rand.Reader)
+		}
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		key, err := tc.key()
+		assert.NilError(t, err)
+
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+		assert.NilError(t, err)
+
+		csr, err := x509.ParseCertificateRequest(der)
+		assert.NilError(t, err)
+
+		reqType, err := requestTypeFor(tc.reqType, csr)
+		if tc.error != "" {
+			assert.Error(t, err, tc.error)
+			return
+		}
+
+		assert.NilError(t, err)
+		assert.Equal(t, reqType, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "rsa",
+			reqType:  v1.RequestTypeOriginRSA,
+			key:      rsaKey(2048),
+			expected: v1.RequestTypeOriginRSA,
+		},
+		{
+			name:     "ecc p256",
+			reqType:  v1.RequestTypeOriginECC,
+			key:      ecdsaKey(elliptic.P256()),
+			expected: v1.RequestTypeOriginECC,
+		},
+		{
+			name:     "ecc p384",
+			reqType:  v1.RequestTypeOriginECC,
+			key:      ecdsaKey(elliptic.P384()),
+			expected: v1.RequestTypeOriginECC,
+		},
+		{
+			name:     "auto rsa",
+			reqType:  v1.RequestTypeAuto,
+			key:      rsaKey(2048),
+			expected: v1.RequestTypeOriginRSA,
+		},
+		{
+			name:     "auto ecc",
+			reqType:  v1.RequestTypeAuto,
+			key:      ecdsaKey(elliptic.P256()),
+			expected: v1.RequestTypeOriginECC,
+		},
+		{
+			name:    "rsa key for ecc",
+			reqType: v1.RequestTypeOriginECC,
+			key:     rsaKey(2048),
+			error:   "CSR cannot be signed with request type OriginECC: RSA key requires request type OriginRSA",
+		},
+		{
+			name:    "ecdsa key for rsa",
+			reqType: v1.RequestTypeOriginRSA,
+			key:     ecdsaKey(elliptic.P256()),
+			error:   "CSR cannot be signed with request type OriginRSA: ECDSA key requires request type OriginECC",
+		},
+		{
+			name:    "small rsa key",
+			reqType: v1.RequestTypeAuto,
+			key:     rsaKey(1024),
+			error:   "CSR cannot be signed with request type Auto: RSA key size 1024 is smaller than 2048 bits",
+		},
+		{
+			name:    "unsupported curve",
+			reqType: v1.RequestTypeOriginECC,
+			key:     ecdsaKey(elliptic.P521()),
+			error:   "CSR cannot be signed with request type OriginECC: ECDSA curve P-521 is not supported, use P-256 or P-384",
+		},
+		{
+			name:    "unsupported algorithm",
+			reqType: v1.RequestTypeAuto,
+			key: func() (crypto.Signer, error) {
+				_, key, err := ed25519.GenerateKey(rand.Reader)
+				return key, err
+			},
+			error: "CSR cannot be signed with request type Auto: Ed25519 keys are not supported, use RSA or ECDSA",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
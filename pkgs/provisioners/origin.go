@@ -5,16 +5,22 @@ package provisioners
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"math"
 	"sync"
+	"time"
 
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/cert-manager/cert-manager/pkg/util/pki"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	"github.com/cloudflare/origin-ca-issuer/internal/metrics"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 )
 
 const (
@@ -22,12 +28,15 @@ const (
 	DefaultDurationInternval = 7
 )
 
-var allowedValidty = []int{7, 30, 90, 365, 730, 1095, 5475}
+var allowedValidty = v1.AllowedValidityDays
 
 // Collection stores cached Provisioners, stored by namespaced names of the
-// issuer.
+// issuer, along with the issuers' rate limiters, which outlive their provisioners.
 type Collection struct {
 	m sync.Map
+
+	mu       sync.Mutex
+	limiters map[types.NamespacedName]*rate.Limiter
 }
 
 // A CollectionItem allows for the namespaced name and provisioner to
@@ -54,7 +63,54 @@ type Provisioner struct {
 	client Signer
 	log    logr.Logger
 
-	reqType v1.RequestType
+	reqType  v1.RequestType
+	policy   *v1.OriginIssuerPolicy
+	validity validity
+	roots    *x509.CertPool
+	limiter  *rate.Limiter
+	clock    clock.PassiveClock
+	version  string
+}
+
+// An Option configures a Provisioner.
+type Option func(*Provisioner)
+
+// WithPolicy restricts the hostnames the provisioner will sign certificates for.
+// CertificateRequests violating the policy fail with a PolicyError before the
+// Cloudflare API is called.
+func WithPolicy(policy *v1.OriginIssuerPolicy) Option {
+	return func(p *Provisioner) {
+		p.policy = policy
+	}
+}
+
+// WithRoots requires certificates returned by the Cloudflare API to chain to one
+// of the Origin CA roots in the pool. Without roots, the chain is verified against
+// the embedded Origin CA root for the request type, if one is embedded.
+func WithRoots(roots *x509.CertPool) Option {
+	return func(p *Provisioner) {
+		p.roots = roots
+	}
+}
+
+// WithClock sets the clock the provisioner's rate limit is measured with.
+func WithClock(clock clock.PassiveClock) Option {
+	return func(p *Provisioner) {
+		p.clock = clock
+	}
+}
+
+// WithVersion records the version of the configuration the provisioner was built
+// from, so callers can tell whether it is still current.
+func WithVersion(version string) Option {
+	return func(p *Provisioner) {
+		p.version = version
+	}
+}
+
+// Version returns the version of the configuration the provisioner was built from.
+func (p *Provisioner) Version() string {
+	return p.version
 }
 
 // Signer implements the Origin CA signing API.
@@ -62,12 +118,50 @@ type Signer interface {
 	Sign(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error)
 }
 
+// Revoker implements the Origin CA revocation API.
+type Revoker interface {
+	Revoke(ctx context.Context, id string) (*cfapi.RevokeResponse, error)
+}
+
+// SignResult is a certificate signed by the Cloudflare API.
+type SignResult struct {
+	// Certificate is the PEM encoded certificate.
+	Certificate []byte
+
+	// CA is the PEM encoded Origin CA root the certificate chains to, or nil if
+	// the provisioner has no roots to verify the chain with.
+	CA []byte
+
+	// ID is the Cloudflare identifier of the certificate.
+	ID string
+
+	// Expiration is when the certificate expires.
+	Expiration time.Time
+
+	// Validity is the normalized validity, in days, the certificate was requested with.
+	Validity int
+}
+
+// WithValidity controls how the provisioner normalizes requested durations to a
+// validity allowed by the Cloudflare API, restricted to validities between minDays
+// and maxDays. A zero minDays or maxDays leaves that bound unrestricted.
+func WithValidity(policy v1.ValidityPolicy, minDays, maxDays int) Option {
+	return func(p *Provisioner) {
+		p.validity = validity{policy: policy, min: minDays, max: maxDays}
+	}
+}
+
 // New returns a new provisioner.
-func New(client Signer, reqType v1.RequestType, log logr.Logger) (*Provisioner, error) {
+func New(client Signer, reqType v1.RequestType, log logr.Logger, opts ...Option) (*Provisioner, error) {
 	p := &Provisioner{
 		client:  client,
 		log:     log,
 		reqType: reqType,
+		clock:   clock.RealClock{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	return p, nil
@@ -75,7 +169,23 @@ func New(client Signer, reqType v1.RequestType, log logr.Logger) (*Provisioner,
 
 // Store adds a provisioner to the collection.
 func (c *Collection) Store(namespacedName types.NamespacedName, provisioner *Provisioner) {
-	c.m.Store(namespacedName, provisioner)
+	if _, loaded := c.m.Swap(namespacedName, provisioner); !loaded {
+		metrics.Provisioners.Inc()
+	}
+}
+
+// Delete removes the provisioner stored with the namespaced name, if any.
+func (c *Collection) Delete(namespacedName types.NamespacedName) {
+	if _, loaded := c.m.LoadAndDelete(namespacedName); loaded {
+		metrics.Provisioners.Dec()
+	}
+}
+
+// Range calls f for each provisioner in the collection, stopping if f returns false.
+func (c *Collection) Range(f func(namespacedName types.NamespacedName, provisioner *Provisioner) bool) {
+	c.m.Range(func(k, v interface{}) bool {
+		return f(k.(types.NamespacedName), v.(*Provisioner))
+	})
 }
 
 // Load returns the stored provisioner, or returns false if nothing is cached with
@@ -91,35 +201,52 @@ func (c *Collection) Load(namespacedName types.NamespacedName) (*Provisioner, bo
 	return p, ok
 }
 
-// Sign uses the Cloduflare API to sign a CertificateRequest. The validity of the CertificateRequest is
-// normalized to the closests validity allowed by the Cloudflare API, which make be significantly different
-// than the validity provided.
-func (p *Provisioner) Sign(ctx context.Context, cr *certmanager.CertificateRequest) (certPem []byte, err error) {
+// Sign uses the Cloudflare API to sign a CertificateRequest, after checking it against the issuer's
+// policy, validity policy, and rate limit, and verifies the returned certificate.
+func (p *Provisioner) Sign(ctx context.Context, cr *certmanager.CertificateRequest) (*SignResult, error) {
 	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode CSR for signing: %s", err)
 	}
 
-	hostnames := csr.DNSNames
-	var duration int
-	if cr.Spec.Duration == nil {
-		duration = DefaultDurationInternval
-	} else {
-		duration = closest(int(cr.Spec.Duration.Duration.Hours()/24), allowedValidty)
+	hostnames, err := hostnamesFor(csr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSR: %w", err)
+	}
+
+	reqType, err := requestTypeFor(p.reqType, csr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSR: %w", err)
+	}
+
+	if err := checkPolicy(p.policy, hostnames); err != nil {
+		return nil, fmt.Errorf("request denied by issuer policy: %w", err)
 	}
 
-	var reqType string
-	switch p.reqType {
-	case v1.RequestTypeOriginECC:
-		reqType = "origin-ecc"
-	case v1.RequestTypeOriginRSA:
-		reqType = "origin-rsa"
+	var requested *time.Duration
+	if cr.Spec.Duration != nil {
+		requested = &cr.Spec.Duration.Duration
+	}
+
+	duration, err := p.validity.normalize(requested)
+	if err != nil {
+		return nil, fmt.Errorf("request denied by issuer validity policy: %w", err)
+	}
+
+	// Requests denied locally do not count against the rate limit.
+	if err := p.reserve(); err != nil {
+		return nil, err
+	}
+
+	apiReqType := "origin-rsa"
+	if reqType == v1.RequestTypeOriginECC {
+		apiReqType = "origin-ecc"
 	}
 
 	resp, err := p.client.Sign(ctx, &cfapi.SignRequest{
 		Hostnames: hostnames,
 		Validity:  duration,
-		Type:      reqType,
+		Type:      apiReqType,
 		CSR:       string(cr.Spec.Request),
 	})
 
@@ -127,7 +254,49 @@ func (p *Provisioner) Sign(ctx context.Context, cr *certmanager.CertificateReque
 		return nil, fmt.Errorf("unable to sign request: %w", err)
 	}
 
-	return []byte(resp.Certificate), nil
+	roots := p.roots
+	if roots == nil {
+		roots = originCARoots[reqType]
+	}
+
+	root, err := verifyCertificate([]byte(resp.Certificate), csr, hostnames, resp.Expiration, roots)
+	if err != nil {
+		return nil, &CertificateError{ID: resp.Id, Reason: err.Error()}
+	}
+
+	var ca []byte
+	if root != nil {
+		ca = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})
+	}
+
+	validity := resp.Validity
+	if validity == 0 {
+		validity = duration
+	}
+
+	metrics.ValidityDays.WithLabelValues(string(reqType)).Observe(float64(validity))
+
+	return &SignResult{
+		Certificate: []byte(resp.Certificate),
+		CA:          ca,
+		ID:          resp.Id,
+		Expiration:  resp.Expiration,
+		Validity:    validity,
+	}, nil
+}
+
+// Revoke uses the Cloudflare API to revoke the certificate with the given ID.
+func (p *Provisioner) Revoke(ctx context.Context, id string) error {
+	r, ok := p.client.(Revoker)
+	if !ok {
+		return fmt.Errorf("unable to revoke certificate %s: client does not support revocation", id)
+	}
+
+	if _, err := r.Revoke(ctx, id); err != nil {
+		return fmt.Errorf("unable to revoke certificate %s: %w", id, err)
+	}
+
+	return nil
 }
 
 func closest(of int, valid []int) int {
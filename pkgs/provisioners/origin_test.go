@@ -11,39 +11,57 @@ import (
 	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
 	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	"github.com/cloudflare/origin-ca-issuer/internal/metrics"
 	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"gotest.tools/v3/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func TestSign(t *testing.T) {
 	type testCase struct {
-		name     string
-		reqType  v1.RequestType
-		req      *certmanager.CertificateRequest
-		signReq  *cfapi.SignRequest
-		expected []byte
+		name    string
+		reqType v1.RequestType
+		req     *certmanager.CertificateRequest
+		signReq *cfapi.SignRequest
 	}
 
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM())
+
 	run := func(t *testing.T, tc testCase) {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		var expected []byte
 		signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
 			assert.DeepEqual(t, req, tc.signReq, cmpopts.IgnoreFields(cfapi.SignRequest{}, "CSR"))
-			return &cfapi.SignResponse{
-				Certificate: "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n",
-			}, nil
+
+			resp, err := signResponse(ca, req, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+			assert.NilError(t, err)
+			expected = []byte(resp.Certificate)
+
+			return resp, nil
 		})
 
-		provisioner, err := New(signer, tc.reqType, logr.Discard())
+		provisioner, err := New(signer, tc.reqType, logr.Discard(), WithRoots(roots))
 		assert.NilError(t, err)
 
 		res, err := provisioner.Sign(ctx, tc.req)
 		assert.NilError(t, err)
-		assert.DeepEqual(t, res, tc.expected)
+		assert.DeepEqual(t, res.Certificate, expected)
+		assert.DeepEqual(t, res.CA, ca.PEM())
+		assert.Equal(t, res.ID, "9001")
+		assert.Equal(t, res.Expiration, time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC))
+		assert.Equal(t, res.Validity, tc.signReq.Validity)
 	}
 
 	testCases := []testCase{
@@ -66,7 +84,6 @@ func TestSign(t *testing.T) {
 				Type:      "origin-rsa",
 				CSR:       "",
 			},
-			expected: []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"),
 		},
 		{
 			name:    "origin ecc",
@@ -87,7 +104,46 @@ func TestSign(t *testing.T) {
 				Type:      "origin-ecc",
 				CSR:       "",
 			},
-			expected: []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"),
+		},
+		{
+			name:    "auto rsa",
+			reqType: v1.RequestTypeAuto,
+			req: cmgen.CertificateRequest("foobar",
+				cmgen.SetCertificateRequestNamespace("default"),
+				cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+				cmgen.SetCertificateRequestCSR((func() []byte {
+					csr, _, err := cmgen.CSR(x509.RSA, cmgen.SetCSRDNSNames("example.com"))
+					assert.NilError(t, err)
+
+					return csr
+				})()),
+			),
+			signReq: &cfapi.SignRequest{
+				Hostnames: []string{"example.com"},
+				Validity:  7,
+				Type:      "origin-rsa",
+				CSR:       "",
+			},
+		},
+		{
+			name:    "auto ecc",
+			reqType: v1.RequestTypeAuto,
+			req: cmgen.CertificateRequest("foobar",
+				cmgen.SetCertificateRequestNamespace("default"),
+				cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 7 * 24 * time.Hour}),
+				cmgen.SetCertificateRequestCSR((func() []byte {
+					csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+					assert.NilError(t, err)
+
+					return csr
+				})()),
+			),
+			signReq: &cfapi.SignRequest{
+				Hostnames: []string{"example.com"},
+				Validity:  7,
+				Type:      "origin-ecc",
+				CSR:       "",
+			},
 		},
 		{
 			name:    "find closest duration",
@@ -108,7 +164,6 @@ func TestSign(t *testing.T) {
 				Type:      "origin-ecc",
 				CSR:       "",
 			},
-			expected: []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"),
 		},
 		{
 			name:    "default duration",
@@ -128,7 +183,6 @@ func TestSign(t *testing.T) {
 				Type:      "origin-ecc",
 				CSR:       "",
 			},
-			expected: []byte("-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"),
 		},
 	}
 
@@ -165,6 +219,89 @@ func TestSign_Error(t *testing.T) {
 	assert.Error(t, err, "unable to sign request: cfapi error")
 }
 
+func TestSign_ValidityMetric(t *testing.T) {
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	other, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM())
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	observed := func(reqType v1.RequestType) uint64 {
+		pb := &dto.Metric{}
+		assert.NilError(t, metrics.ValidityDays.WithLabelValues(string(reqType)).(prometheus.Metric).Write(pb))
+
+		return pb.GetHistogram().GetSampleCount()
+	}
+
+	type testCase struct {
+		name     string
+		signer   Signer
+		error    string
+		observed uint64
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		provisioner, err := New(tc.signer, v1.RequestTypeAuto, logr.Discard(), WithRoots(roots))
+		assert.NilError(t, err)
+
+		ecc, auto := observed(v1.RequestTypeOriginECC), observed(v1.RequestTypeAuto)
+
+		_, err = provisioner.Sign(context.Background(), req)
+		if tc.error != "" {
+			assert.ErrorContains(t, err, tc.error)
+		} else {
+			assert.NilError(t, err)
+		}
+
+		assert.Equal(t, observed(v1.RequestTypeOriginECC)-ecc, tc.observed)
+		assert.Equal(t, observed(v1.RequestTypeAuto)-auto, uint64(0))
+	}
+
+	testCases := []testCase{
+		{
+			name: "issued",
+			signer: SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+				return signResponse(ca, req, time.Now().Add(24*time.Hour).Truncate(time.Second))
+			}),
+			observed: 1,
+		},
+		{
+			name: "api error",
+			signer: SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+				return nil, errors.New("cfapi error")
+			}),
+			error: "unable to sign request: cfapi error",
+		},
+		{
+			name: "untrusted certificate",
+			signer: SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+				return signResponse(other, req, time.Now().Add(24*time.Hour).Truncate(time.Second))
+			}),
+			error: "certificate does not chain to a trusted Origin CA root",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 func TestClosest(t *testing.T) {
 	index := func(x int, s []int) int {
 		for i, n := range s {
@@ -185,8 +322,53 @@ func TestClosest(t *testing.T) {
 	assert.NilError(t, err)
 }
 
+// signResponse returns a response for the request, with a certificate for the
+// requested hostnames signed by ca.
+func signResponse(ca *fakeapi.Authority, req *cfapi.SignRequest, expiration time.Time) (*cfapi.SignResponse, error) {
+	cert, err := ca.SignCSR([]byte(req.CSR), req.Hostnames, expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfapi.SignResponse{
+		Id:          "9001",
+		Certificate: string(cert),
+		Hostnames:   req.Hostnames,
+		Expiration:  expiration,
+	}, nil
+}
+
 type SignerFunc func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error)
 
 func (f SignerFunc) Sign(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
 	return f(ctx, req)
 }
+
+func TestCollection(t *testing.T) {
+	foo := types.NamespacedName{Namespace: "default", Name: "foo"}
+	bar := types.NamespacedName{Name: "bar"}
+
+	c := CollectionWith([]CollectionItem{
+		{NamespacedName: foo, Provisioner: &Provisioner{}},
+		{NamespacedName: bar, Provisioner: &Provisioner{}},
+	})
+
+	var names []types.NamespacedName
+	c.Range(func(name types.NamespacedName, _ *Provisioner) bool {
+		names = append(names, name)
+		return true
+	})
+	assert.DeepEqual(t, names, []types.NamespacedName{bar, foo}, cmpopts.SortSlices(func(a, b types.NamespacedName) bool {
+		return a.String() < b.String()
+	}))
+
+	c.Delete(foo)
+	_, ok := c.Load(foo)
+	assert.Assert(t, !ok)
+
+	_, ok = c.Load(bar)
+	assert.Assert(t, ok)
+
+	// Deleting a missing provisioner is a no-op.
+	c.Delete(foo)
+}
@@ -0,0 +1,104 @@
+package provisioners
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+)
+
+// PolicyError is returned when a CertificateRequest requests a hostname its
+// issuer's policy does not allow.
+type PolicyError struct {
+	// Hostname is the requested hostname violating the policy.
+	Hostname string
+
+	// Pattern is the denied pattern the hostname matched, if any.
+	Pattern string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Pattern != "" && strings.HasPrefix(e.Hostname, "*") && !strings.HasPrefix(e.Pattern, "*") {
+		return fmt.Sprintf("hostname %q covers denied domain %q", e.Hostname, e.Pattern)
+	}
+
+	if e.Pattern != "" {
+		return fmt.Sprintf("hostname %q matches denied domain %q", e.Hostname, e.Pattern)
+	}
+
+	return fmt.Sprintf("hostname %q does not match any allowed domain", e.Hostname)
+}
+
+// checkPolicy returns a PolicyError for the first hostname violating the policy.
+// A nil policy allows every hostname.
+func checkPolicy(policy *v1.OriginIssuerPolicy, hostnames []string) error {
+	if policy == nil {
+		return nil
+	}
+
+	for _, hostname := range hostnames {
+		name := normalizeDomain(hostname)
+
+		if pattern, ok := matchDomain(policy.DeniedDomains, name); ok {
+			return &PolicyError{Hostname: hostname, Pattern: pattern}
+		}
+
+		if pattern, ok := coveredDomain(policy.DeniedDomains, name); ok {
+			return &PolicyError{Hostname: hostname, Pattern: pattern}
+		}
+
+		if len(policy.AllowedDomains) == 0 {
+			continue
+		}
+
+		if _, ok := matchDomain(policy.AllowedDomains, name); !ok {
+			return &PolicyError{Hostname: hostname}
+		}
+	}
+
+	return nil
+}
+
+// matchDomain returns the first pattern matching the normalized hostname.
+func matchDomain(patterns []string, hostname string) (string, bool) {
+	for _, pattern := range patterns {
+		p := normalizeDomain(pattern)
+
+		if suffix, ok := strings.CutPrefix(p, "*"); ok {
+			if len(hostname) > len(suffix) && strings.HasSuffix(hostname, suffix) {
+				return pattern, true
+			}
+
+			continue
+		}
+
+		if hostname == p {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+// coveredDomain returns the first pattern naming a hostname covered by the
+// normalized wildcard hostname. A certificate for *.example.com is also valid for
+// secret.example.com, so must be denied if secret.example.com is.
+func coveredDomain(patterns []string, hostname string) (string, bool) {
+	suffix, ok := strings.CutPrefix(hostname, "*")
+	if !ok {
+		return "", false
+	}
+
+	for _, pattern := range patterns {
+		label, ok := strings.CutSuffix(normalizeDomain(pattern), suffix)
+		if ok && label != "" && !strings.ContainsAny(label, ".*") {
+			return pattern, true
+		}
+	}
+
+	return "", false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
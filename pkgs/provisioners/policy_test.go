@@ -0,0 +1,145 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	type testCase struct {
+		name      string
+		policy    *v1.OriginIssuerPolicy
+		hostnames []string
+		error     string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := checkPolicy(tc.policy, tc.hostnames)
+		if tc.error == "" {
+			assert.NilError(t, err)
+			return
+		}
+
+		var policyErr *PolicyError
+		assert.Assert(t, errors.As(err, &policyErr))
+		assert.Error(t, err, tc.error)
+	}
+
+	testCases := []testCase{
+		{
+			name:      "no policy",
+			hostnames: []string{"example.com", "example.org"},
+		},
+		{
+			name:      "empty policy",
+			policy:    &v1.OriginIssuerPolicy{},
+			hostnames: []string{"example.com", "example.org"},
+		},
+		{
+			name:      "exact allowed",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"example.com"}},
+			hostnames: []string{"example.com"},
+		},
+		{
+			name:      "exact does not match subdomain",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"example.com"}},
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     `hostname "www.example.com" does not match any allowed domain`,
+		},
+		{
+			name:      "wildcard matches any depth",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"*.example.com"}},
+			hostnames: []string{"www.example.com", "a.b.example.com", "*.example.com", "*.b.example.com"},
+		},
+		{
+			name:      "wildcard does not match apex",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"*.example.com"}},
+			hostnames: []string{"example.com"},
+			error:     `hostname "example.com" does not match any allowed domain`,
+		},
+		{
+			name:      "wildcard does not match partial label",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"*.example.com"}},
+			hostnames: []string{"notexample.com"},
+			error:     `hostname "notexample.com" does not match any allowed domain`,
+		},
+		{
+			name:      "case and trailing dot insensitive",
+			policy:    &v1.OriginIssuerPolicy{AllowedDomains: []string{"*.Example.COM."}},
+			hostnames: []string{"WWW.example.com."},
+		},
+		{
+			name:      "denied",
+			policy:    &v1.OriginIssuerPolicy{DeniedDomains: []string{"*.internal.example.com"}},
+			hostnames: []string{"www.example.com", "db.internal.example.com"},
+			error:     `hostname "db.internal.example.com" matches denied domain "*.internal.example.com"`,
+		},
+		{
+			name: "denied takes precedence",
+			policy: &v1.OriginIssuerPolicy{
+				AllowedDomains: []string{"*.example.com"},
+				DeniedDomains:  []string{"admin.example.com"},
+			},
+			hostnames: []string{"admin.example.com"},
+			error:     `hostname "admin.example.com" matches denied domain "admin.example.com"`,
+		},
+		{
+			name: "wildcard covers denied",
+			policy: &v1.OriginIssuerPolicy{
+				AllowedDomains: []string{"*.example.com"},
+				DeniedDomains:  []string{"Secret.Example.com."},
+			},
+			hostnames: []string{"*.example.com"},
+			error:     `hostname "*.example.com" covers denied domain "Secret.Example.com."`,
+		},
+		{
+			name:      "wildcard does not cover deeper denied",
+			policy:    &v1.OriginIssuerPolicy{DeniedDomains: []string{"db.internal.example.com", "*.internal.example.com", "example.com"}},
+			hostnames: []string{"*.example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSign_PolicyViolation(t *testing.T) {
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		t.Fatal("unexpected call to the Cloudflare API")
+		return nil, nil
+	})
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com", "example.org"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	provisioner, err := New(signer, v1.RequestTypeOriginECC, logr.Discard(), WithPolicy(&v1.OriginIssuerPolicy{
+		AllowedDomains: []string{"example.com"},
+	}))
+	assert.NilError(t, err)
+
+	_, err = provisioner.Sign(context.Background(), req)
+	assert.Error(t, err, `request denied by issuer policy: hostname "example.org" does not match any allowed domain`)
+
+	var policyErr *PolicyError
+	assert.Assert(t, errors.As(err, &policyErr))
+	assert.Equal(t, policyErr.Hostname, "example.org")
+}
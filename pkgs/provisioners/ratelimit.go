@@ -0,0 +1,107 @@
+package provisioners
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RateLimitError is returned when the issuer's rate limit does not allow another
+// certificate to be signed yet. Signing may be retried after RetryAfter.
+type RateLimitError struct {
+	// RetryAfter is how long until the rate limit allows another certificate
+	// to be signed.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
+// WithRateLimit limits how often the provisioner signs certificates, with a token
+// bucket holding up to burst requests and refilled at requestsPerMinute. A burst
+// lower than 1 is raised to 1, and a requestsPerMinute of 0 disables the limit.
+// Requests over the limit fail with a RateLimitError without calling the
+// Cloudflare API.
+func WithRateLimit(requestsPerMinute, burst int) Option {
+	return WithRateLimiter(newRateLimiter(requestsPerMinute, burst))
+}
+
+// WithRateLimiter limits how often the provisioner signs certificates with the
+// limiter, which may be shared with earlier provisioners of the same issuer so
+// rebuilding the provisioner does not refill it. A nil limiter disables the limit.
+func WithRateLimiter(limiter *rate.Limiter) Option {
+	return func(p *Provisioner) {
+		p.limiter = limiter
+	}
+}
+
+// newRateLimiter returns a token bucket holding up to burst requests and refilled
+// at requestsPerMinute, or nil if requestsPerMinute is 0.
+func newRateLimiter(requestsPerMinute, burst int) *rate.Limiter {
+	if requestsPerMinute <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(float64(requestsPerMinute)/60), burst)
+}
+
+// RateLimiter returns the rate limiter of the issuer with the namespaced name,
+// limiting it to requestsPerMinute with burst as described by WithRateLimit. The
+// issuer's stored limiter is returned, with the tokens it has left, unless the
+// limit changed, in which case it is replaced by a full one.
+func (c *Collection) RateLimiter(namespacedName types.NamespacedName, requestsPerMinute, burst int) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limiter := newRateLimiter(requestsPerMinute, burst)
+	if limiter == nil {
+		delete(c.limiters, namespacedName)
+
+		return nil
+	}
+
+	if stored, ok := c.limiters[namespacedName]; ok && stored.Limit() == limiter.Limit() && stored.Burst() == limiter.Burst() {
+		return stored
+	}
+
+	if c.limiters == nil {
+		c.limiters = map[types.NamespacedName]*rate.Limiter{}
+	}
+	c.limiters[namespacedName] = limiter
+
+	return limiter
+}
+
+// DeleteRateLimiter removes the rate limiter stored for the namespaced name, if any.
+func (c *Collection) DeleteRateLimiter(namespacedName types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.limiters, namespacedName)
+}
+
+// reserve takes a token from the rate limit, failing with a RateLimitError if
+// none is available.
+func (p *Provisioner) reserve() error {
+	if p.limiter == nil {
+		return nil
+	}
+
+	now := p.clock.Now()
+
+	r := p.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+
+		return &RateLimitError{RetryAfter: delay}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	certmanager "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/types"
+	fakeClock "k8s.io/utils/clock/testing"
+)
+
+func TestSign_RateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	var calls int
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		calls++
+
+		return signResponse(ca, req, time.Now().Add(7*24*time.Hour))
+	})
+
+	request := func(hostname string) *certmanager.CertificateRequest {
+		return cmgen.CertificateRequest("foobar",
+			cmgen.SetCertificateRequestNamespace("default"),
+			cmgen.SetCertificateRequestCSR((func() []byte {
+				csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames(hostname))
+				assert.NilError(t, err)
+
+				return csr
+			})()),
+		)
+	}
+
+	clock := fakeClock.NewFakePassiveClock(time.Now())
+
+	provisioner, err := New(signer, v1.RequestTypeOriginECC, logr.Discard(),
+		WithPolicy(&v1.OriginIssuerPolicy{DeniedDomains: []string{"example.org"}}),
+		WithRateLimit(1, 2),
+		WithClock(clock),
+	)
+	assert.NilError(t, err)
+
+	// Requests denied by the issuer's policy do not take a token.
+	_, err = provisioner.Sign(ctx, request("example.org"))
+	var policyErr *PolicyError
+	assert.Assert(t, errors.As(err, &policyErr))
+
+	for i := 0; i < 2; i++ {
+		_, err = provisioner.Sign(ctx, request("example.com"))
+		assert.NilError(t, err)
+	}
+
+	_, err = provisioner.Sign(ctx, request("example.com"))
+	var rateLimitErr *RateLimitError
+	assert.Assert(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, rateLimitErr.RetryAfter, time.Minute)
+	assert.Equal(t, calls, 2)
+
+	clock.SetTime(clock.Now().Add(time.Minute))
+	_, err = provisioner.Sign(ctx, request("example.com"))
+	assert.NilError(t, err)
+	assert.Equal(t, calls, 3)
+}
+
+func TestWithRateLimit(t *testing.T) {
+	type testCase struct {
+		name              string
+		requestsPerMinute int
+		burst             int
+		limited           bool
+		wantBurst         int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		p, err := New(nil, v1.RequestTypeOriginECC, logr.Discard(), WithRateLimit(tc.requestsPerMinute, tc.burst))
+		assert.NilError(t, err)
+
+		if !tc.limited {
+			assert.Assert(t, p.limiter == nil)
+			return
+		}
+
+		assert.Assert(t, p.limiter != nil)
+		assert.Equal(t, p.limiter.Burst(), tc.wantBurst)
+	}
+
+	testCases := []testCase{
+		{
+			name: "disabled",
+		},
+		{
+			name:              "burst",
+			requestsPerMinute: 60,
+			burst:             10,
+			limited:           true,
+			wantBurst:         10,
+		},
+		{
+			name:              "default burst",
+			requestsPerMinute: 60,
+			limited:           true,
+			wantBurst:         1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestCollection_RateLimiter(t *testing.T) {
+	c := CollectionWith(nil)
+	foo := types.NamespacedName{Namespace: "default", Name: "foo"}
+	bar := types.NamespacedName{Namespace: "default", Name: "bar"}
+
+	limiter := c.RateLimiter(foo, 60, 10)
+	assert.Assert(t, limiter != nil)
+	assert.Equal(t, limiter.Burst(), 10)
+
+	assert.Assert(t, c.RateLimiter(foo, 60, 10) == limiter, "expected the limiter to be reused")
+	assert.Assert(t, c.RateLimiter(bar, 60, 10) != limiter, "expected a limiter per issuer")
+
+	changed := c.RateLimiter(foo, 60, 20)
+	assert.Assert(t, changed != limiter, "expected the limiter to be replaced when the burst changes")
+	assert.Equal(t, changed.Burst(), 20)
+
+	assert.Assert(t, c.RateLimiter(foo, 120, 20) != changed, "expected the limiter to be replaced when the rate changes")
+
+	assert.Assert(t, c.RateLimiter(foo, 0, 0) == nil)
+	assert.Assert(t, c.RateLimiter(foo, 120, 20) != changed)
+
+	stored := c.RateLimiter(bar, 60, 10)
+	c.DeleteRateLimiter(bar)
+	assert.Assert(t, c.RateLimiter(bar, 60, 10) != stored, "expected a new limiter once deleted")
+}
@@ -0,0 +1,65 @@
+package provisioners
+
+import (
+	"crypto/x509"
+	"embed"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+)
+
+// rootFiles are the published Origin CA roots, from
+// https://developers.cloudflare.com/ssl/origin-configuration/origin-ca/.
+//
+//go:embed roots/*.pem
+var rootFiles embed.FS
+
+// originCARoots are the embedded Origin CA roots, by the request type of the
+// certificates they sign.
+var originCARoots = mustLoadRoots(rootFiles)
+
+// mustLoadRoots returns pools of the roots in every PEM file in fsys, by the
+// request type matching the root's key algorithm.
+func mustLoadRoots(fsys fs.FS) map[v1.RequestType]*x509.CertPool {
+	paths, err := fs.Glob(fsys, "roots/*.pem")
+	if err != nil {
+		panic(err)
+	}
+
+	pools := map[v1.RequestType]*x509.CertPool{}
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			panic(err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			panic(fmt.Sprintf("no certificate found in %s", path))
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			panic(fmt.Sprintf("invalid certificate in %s: %s", path, err))
+		}
+
+		var reqType v1.RequestType
+		switch cert.PublicKeyAlgorithm {
+		case x509.RSA:
+			reqType = v1.RequestTypeOriginRSA
+		case x509.ECDSA:
+			reqType = v1.RequestTypeOriginECC
+		default:
+			panic(fmt.Sprintf("unexpected %s root in %s", cert.PublicKeyAlgorithm, path))
+		}
+
+		if pools[reqType] == nil {
+			pools[reqType] = x509.NewCertPool()
+		}
+		pools[reqType].AddCert(cert)
+	}
+
+	return pools
+}
@@ -0,0 +1,145 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+)
+
+func TestOriginCARoots(t *testing.T) {
+	paths, err := fs.Glob(rootFiles, "roots/*.pem")
+	assert.NilError(t, err)
+	assert.Assert(t, len(paths) > 0)
+
+	for _, path := range paths {
+		data, err := fs.ReadFile(rootFiles, path)
+		assert.NilError(t, err)
+
+		block, _ := pem.Decode(data)
+		assert.Assert(t, block != nil, path)
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		assert.NilError(t, err, path)
+
+		// The embedded roots must be the published certificates byte for byte.
+		assert.Assert(t, cert.IsCA, path)
+		assert.NilError(t, cert.CheckSignatureFrom(cert), path)
+		assert.DeepEqual(t, cert.Subject.Organization, []string{"CloudFlare, Inc."})
+	}
+
+	assert.Assert(t, originCARoots[v1.RequestTypeOriginRSA] != nil)
+}
+
+func TestMustLoadRoots(t *testing.T) {
+	rsa, err := fakeapi.NewAuthority("RSA", time.Now())
+	assert.NilError(t, err)
+
+	ecc, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	roots := mustLoadRoots(fstest.MapFS{
+		"roots/rsa.pem": {Data: rsa.PEM()},
+		"roots/ecc.pem": {Data: ecc.PEM()},
+	})
+
+	rsaRoots := x509.NewCertPool()
+	rsaRoots.AppendCertsFromPEM(rsa.PEM())
+
+	eccRoots := x509.NewCertPool()
+	eccRoots.AppendCertsFromPEM(ecc.PEM())
+
+	assert.Assert(t, roots[v1.RequestTypeOriginRSA].Equal(rsaRoots))
+	assert.Assert(t, roots[v1.RequestTypeOriginECC].Equal(eccRoots))
+}
+
+func TestSign_DefaultRoots(t *testing.T) {
+	ca, err := fakeapi.NewAuthority("RSA", time.Now())
+	assert.NilError(t, err)
+
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		return signResponse(ca, req, time.Now().Add(24*time.Hour).Truncate(time.Second))
+	})
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.RSA, cmgen.SetCSRDNSNames("example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	provisioner, err := New(signer, v1.RequestTypeOriginRSA, logr.Discard())
+	assert.NilError(t, err)
+
+	_, err = provisioner.Sign(context.Background(), req)
+
+	var certErr *CertificateError
+	assert.Assert(t, errors.As(err, &certErr))
+	assert.ErrorContains(t, err, "certificate does not chain to a trusted Origin CA root")
+}
+
+func TestSign_DefaultECCRoots(t *testing.T) {
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	other, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	// Stand in for the embedded ECC root, which signs OriginECC certificates.
+	defaults := originCARoots
+	originCARoots = mustLoadRoots(fstest.MapFS{"roots/origin_ca_ecc_root.pem": {Data: ca.PEM()}})
+	t.Cleanup(func() { originCARoots = defaults })
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	for _, reqType := range []v1.RequestType{v1.RequestTypeOriginECC, v1.RequestTypeAuto} {
+		reqType := reqType
+		t.Run(string(reqType), func(t *testing.T) {
+			signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+				return signResponse(ca, req, time.Now().Add(24*time.Hour).Truncate(time.Second))
+			})
+
+			provisioner, err := New(signer, reqType, logr.Discard())
+			assert.NilError(t, err)
+
+			res, err := provisioner.Sign(context.Background(), req)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, res.CA, ca.PEM())
+
+			untrusted := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+				return signResponse(other, req, time.Now().Add(24*time.Hour).Truncate(time.Second))
+			})
+
+			provisioner, err = New(untrusted, reqType, logr.Discard())
+			assert.NilError(t, err)
+
+			_, err = provisioner.Sign(context.Background(), req)
+
+			var certErr *CertificateError
+			assert.Assert(t, errors.As(err, &certErr))
+			assert.ErrorContains(t, err, "certificate does not chain to a trusted Origin CA root")
+		})
+	}
+}
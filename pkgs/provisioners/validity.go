@@ -0,0 +1,87 @@
+package provisioners
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+)
+
+// ValidityError is returned when the requested duration of a CertificateRequest
+// is not allowed by its issuer's validity policy.
+type ValidityError struct {
+	// Requested is the requested duration.
+	Requested time.Duration
+
+	// Allowed are the validities, in days, the issuer allows.
+	Allowed []int
+}
+
+func (e *ValidityError) Error() string {
+	return fmt.Sprintf("requested duration %s is not one of the allowed validities %v (in days)", e.Requested, e.Allowed)
+}
+
+// validity normalizes requested durations to a validity allowed by the
+// Cloudflare API, according to an issuer's validity policy.
+type validity struct {
+	policy v1.ValidityPolicy
+	min    int
+	max    int
+}
+
+// allowed returns the validities, in days, within the minimum and maximum.
+func (v validity) allowed() []int {
+	var allowed []int
+	for _, days := range allowedValidty {
+		if (v.min == 0 || days >= v.min) && (v.max == 0 || days <= v.max) {
+			allowed = append(allowed, days)
+		}
+	}
+
+	return allowed
+}
+
+// normalize returns the validity, in days, to request for the requested duration.
+// A nil duration requests the allowed validity closest to the default.
+func (v validity) normalize(requested *time.Duration) (int, error) {
+	allowed := v.allowed()
+	if len(allowed) == 0 {
+		return 0, fmt.Errorf("no validity allowed between %d and %d days", v.min, v.max)
+	}
+
+	if requested == nil {
+		return closest(DefaultDurationInternval, allowed), nil
+	}
+
+	days := int(requested.Hours() / 24)
+
+	switch v.policy {
+	case v1.ValidityPolicyStrict:
+		i := sort.SearchInts(allowed, days)
+		if *requested%(24*time.Hour) != 0 || i == len(allowed) || allowed[i] != days {
+			return 0, &ValidityError{Requested: *requested, Allowed: allowed}
+		}
+
+		return days, nil
+	case v1.ValidityPolicyRoundUp:
+		// Any fraction of a day rounds up to the next whole day.
+		if *requested%(24*time.Hour) != 0 {
+			days++
+		}
+
+		if i := sort.SearchInts(allowed, days); i < len(allowed) {
+			return allowed[i], nil
+		}
+
+		return allowed[len(allowed)-1], nil
+	case v1.ValidityPolicyRoundDown:
+		if i := sort.SearchInts(allowed, days+1); i > 0 {
+			return allowed[i-1], nil
+		}
+
+		return allowed[0], nil
+	default:
+		return closest(days, allowed), nil
+	}
+}
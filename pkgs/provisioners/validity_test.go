@@ -0,0 +1,190 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidityNormalize(t *testing.T) {
+	const day = 24 * time.Hour
+
+	type testCase struct {
+		name      string
+		validity  validity
+		requested *time.Duration
+		expected  int
+		error     string
+	}
+
+	duration := func(d time.Duration) *time.Duration {
+		return &d
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		days, err := tc.validity.normalize(tc.requested)
+		if tc.error != "" {
+			assert.Error(t, err, tc.error)
+			return
+		}
+
+		assert.NilError(t, err)
+		assert.Equal(t, days, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "default duration",
+			expected: 7,
+		},
+		{
+			name:     "default duration clamped",
+			validity: validity{min: 30},
+			expected: 30,
+		},
+		{
+			name:      "nearest",
+			requested: duration(60 * day),
+			expected:  30,
+		},
+		{
+			name:      "nearest explicitly",
+			validity:  validity{policy: v1.ValidityPolicyNearest},
+			requested: duration(80 * day),
+			expected:  90,
+		},
+		{
+			name:      "round up",
+			validity:  validity{policy: v1.ValidityPolicyRoundUp},
+			requested: duration(60 * day),
+			expected:  90,
+		},
+		{
+			name:      "round up exact",
+			validity:  validity{policy: v1.ValidityPolicyRoundUp},
+			requested: duration(90 * day),
+			expected:  90,
+		},
+		{
+			name:      "round up partial day",
+			validity:  validity{policy: v1.ValidityPolicyRoundUp},
+			requested: duration(90*day + time.Hour),
+			expected:  365,
+		},
+		{
+			name:      "round up beyond longest",
+			validity:  validity{policy: v1.ValidityPolicyRoundUp},
+			requested: duration(6000 * day),
+			expected:  5475,
+		},
+		{
+			name:      "round down",
+			validity:  validity{policy: v1.ValidityPolicyRoundDown},
+			requested: duration(60 * day),
+			expected:  30,
+		},
+		{
+			name:      "round down exact",
+			validity:  validity{policy: v1.ValidityPolicyRoundDown},
+			requested: duration(365 * day),
+			expected:  365,
+		},
+		{
+			name:      "round down below shortest",
+			validity:  validity{policy: v1.ValidityPolicyRoundDown},
+			requested: duration(day),
+			expected:  7,
+		},
+		{
+			name:      "strict",
+			validity:  validity{policy: v1.ValidityPolicyStrict},
+			requested: duration(90 * day),
+			expected:  90,
+		},
+		{
+			name:      "strict mismatch",
+			validity:  validity{policy: v1.ValidityPolicyStrict},
+			requested: duration(60 * day),
+			error:     "requested duration 1440h0m0s is not one of the allowed validities [7 30 90 365 730 1095 5475] (in days)",
+		},
+		{
+			name:      "strict partial day",
+			validity:  validity{policy: v1.ValidityPolicyStrict},
+			requested: duration(90*day + time.Hour),
+			error:     "requested duration 2161h0m0s is not one of the allowed validities [7 30 90 365 730 1095 5475] (in days)",
+		},
+		{
+			name:      "strict outside bounds",
+			validity:  validity{policy: v1.ValidityPolicyStrict, max: 365},
+			requested: duration(730 * day),
+			error:     "requested duration 17520h0m0s is not one of the allowed validities [7 30 90 365] (in days)",
+		},
+		{
+			name:      "nearest clamped to max",
+			validity:  validity{max: 365},
+			requested: duration(3650 * day),
+			expected:  365,
+		},
+		{
+			name:      "round down clamped to min",
+			validity:  validity{policy: v1.ValidityPolicyRoundDown, min: 90},
+			requested: duration(60 * day),
+			expected:  90,
+		},
+		{
+			name:      "round up clamped to max",
+			validity:  validity{policy: v1.ValidityPolicyRoundUp, max: 400},
+			requested: duration(500 * day),
+			expected:  365,
+		},
+		{
+			name:      "no allowed validity",
+			validity:  validity{min: 100, max: 300},
+			requested: duration(200 * day),
+			error:     "no validity allowed between 100 and 300 days",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSign_ValidityViolation(t *testing.T) {
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		t.Fatal("unexpected call to the Cloudflare API")
+		return nil, nil
+	})
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestDuration(&metav1.Duration{Duration: 60 * 24 * time.Hour}),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	provisioner, err := New(signer, v1.RequestTypeOriginECC, logr.Discard(), WithValidity(v1.ValidityPolicyStrict, 0, 0))
+	assert.NilError(t, err)
+
+	_, err = provisioner.Sign(context.Background(), req)
+	assert.Error(t, err, "request denied by issuer validity policy: requested duration 1440h0m0s is not one of the allowed validities [7 30 90 365 730 1095 5475] (in days)")
+
+	var validityErr *ValidityError
+	assert.Assert(t, errors.As(err, &validityErr))
+}
@@ -0,0 +1,110 @@
+package provisioners
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// CertificateError is returned when the certificate returned by the Cloudflare API
+// does not match the certificate that was requested, or cannot be trusted.
+type CertificateError struct {
+	// ID is the Cloudflare identifier of the certificate.
+	ID string
+
+	// Reason describes why the certificate failed verification.
+	Reason string
+}
+
+func (e *CertificateError) Error() string {
+	return fmt.Sprintf("certificate %s returned by the Cloudflare API failed verification: %s", e.ID, e.Reason)
+}
+
+// verifyCertificate checks the PEM encoded certificate returned by the Cloudflare
+// API was issued for the CSR's public key, for exactly the requested hostnames, and
+// expires at expiration, if known. If roots is set, the certificate must also chain
+// to one of them, through any intermediates following it in the PEM, and the root
+// it chains to is returned.
+func verifyCertificate(data []byte, csr *x509.CertificateRequest, hostnames []string, expiration time.Time, roots *x509.CertPool) (*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block of type %q", block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+
+	cert := certs[0]
+
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(csr.PublicKey) {
+		return nil, fmt.Errorf("public key does not match the CSR")
+	}
+
+	if len(cert.IPAddresses) > 0 || len(cert.URIs) > 0 || len(cert.EmailAddresses) > 0 {
+		return nil, fmt.Errorf("certificate contains SANs other than DNS names")
+	}
+
+	if got, want := sortedNames(cert.DNSNames), sortedNames(hostnames); !slices.Equal(got, want) {
+		return nil, fmt.Errorf("DNS names %v do not match the requested hostnames %v", got, want)
+	}
+
+	if !expiration.IsZero() && !cert.NotAfter.Equal(expiration.Truncate(time.Second)) {
+		return nil, fmt.Errorf("expiration %s does not match the reported expiration %s", cert.NotAfter.UTC(), expiration.UTC())
+	}
+
+	if roots == nil {
+		return nil, nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	// The chain is verified as of issuance, so clock skew between the controller and
+	// the Cloudflare API cannot fail a freshly issued certificate.
+	chains, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not chain to a trusted Origin CA root: %w", err)
+	}
+
+	chain := chains[0]
+
+	return chain[len(chain)-1], nil
+}
+
+func sortedNames(names []string) []string {
+	sorted := make([]string, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, normalizeDomain(name))
+	}
+
+	slices.Sort(sorted)
+
+	return sorted
+}
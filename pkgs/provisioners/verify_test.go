@@ -0,0 +1,192 @@
+package provisioners
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	cmgen "github.com/cert-manager/cert-manager/test/unit/gen"
+	"github.com/cloudflare/origin-ca-issuer/internal/cfapi"
+	fakeapi "github.com/cloudflare/origin-ca-issuer/internal/cfapi/testing"
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+)
+
+func TestVerifyCertificate(t *testing.T) {
+	type testCase struct {
+		name       string
+		cert       []byte
+		hostnames  []string
+		expiration time.Time
+		roots      *x509.CertPool
+		ca         []byte
+		error      string
+	}
+
+	expiration := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	ca, err := fakeapi.NewAuthority("ECC", time.Now())
+	assert.NilError(t, err)
+
+	other, err := fakeapi.NewAuthority("RSA", time.Now())
+	assert.NilError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM())
+
+	otherRoots := x509.NewCertPool()
+	otherRoots.AppendCertsFromPEM(other.PEM())
+
+	allRoots := x509.NewCertPool()
+	allRoots.AppendCertsFromPEM(append(other.PEM(), ca.PEM()...))
+
+	csrPEM, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com", "www.example.com"))
+	assert.NilError(t, err)
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	assert.NilError(t, err)
+
+	sign := func(csrPEM []byte, hostnames ...string) []byte {
+		cert, err := ca.SignCSR(csrPEM, hostnames, expiration)
+		assert.NilError(t, err)
+
+		return cert
+	}
+
+	otherCSR, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com", "www.example.com"))
+	assert.NilError(t, err)
+
+	cert := sign(csrPEM, "example.com", "www.example.com")
+
+	run := func(t *testing.T, tc testCase) {
+		root, err := verifyCertificate(tc.cert, csr, tc.hostnames, tc.expiration, tc.roots)
+		if tc.error == "" {
+			assert.NilError(t, err)
+
+			var ca []byte
+			if root != nil {
+				ca = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})
+			}
+			assert.DeepEqual(t, ca, tc.ca)
+
+			return
+		}
+
+		assert.ErrorContains(t, err, tc.error)
+	}
+
+	testCases := []testCase{
+		{
+			name:       "valid",
+			cert:       cert,
+			hostnames:  []string{"example.com", "www.example.com"},
+			expiration: expiration,
+			roots:      roots,
+			ca:         ca.PEM(),
+		},
+		{
+			name:       "root the certificate chains to",
+			cert:       cert,
+			hostnames:  []string{"example.com", "www.example.com"},
+			expiration: expiration,
+			roots:      allRoots,
+			ca:         ca.PEM(),
+		},
+		{
+			name:      "hostnames in any order",
+			cert:      cert,
+			hostnames: []string{"www.example.com", "example.com"},
+		},
+		{
+			name:       "unknown expiration",
+			cert:       cert,
+			hostnames:  []string{"example.com", "www.example.com"},
+			expiration: time.Time{},
+		},
+		{
+			name:      "empty",
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     "no certificate found",
+		},
+		{
+			name:      "not a certificate",
+			cert:      csrPEM,
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     `unexpected PEM block of type "CERTIFICATE REQUEST"`,
+		},
+		{
+			name:      "different public key",
+			cert:      sign(otherCSR, "example.com", "www.example.com"),
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     "public key does not match the CSR",
+		},
+		{
+			name:      "missing hostname",
+			cert:      sign(csrPEM, "example.com"),
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     "DNS names [example.com] do not match the requested hostnames [example.com www.example.com]",
+		},
+		{
+			name:      "extra hostname",
+			cert:      sign(csrPEM, "example.com", "www.example.com", "example.org"),
+			hostnames: []string{"example.com", "www.example.com"},
+			error:     "DNS names [example.com example.org www.example.com] do not match the requested hostnames [example.com www.example.com]",
+		},
+		{
+			name:       "different expiration",
+			cert:       cert,
+			hostnames:  []string{"example.com", "www.example.com"},
+			expiration: expiration.Add(24 * time.Hour),
+			error:      "expiration 2030-01-01 00:00:00 +0000 UTC does not match the reported expiration 2030-01-02 00:00:00 +0000 UTC",
+		},
+		{
+			name:      "untrusted root",
+			cert:      cert,
+			hostnames: []string{"example.com", "www.example.com"},
+			roots:     otherRoots,
+			error:     "certificate does not chain to a trusted Origin CA root",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSign_InvalidCertificate(t *testing.T) {
+	signer := SignerFunc(func(ctx context.Context, req *cfapi.SignRequest) (*cfapi.SignResponse, error) {
+		return &cfapi.SignResponse{
+			Id:          "9001",
+			Certificate: "bogus",
+			Expiration:  time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}, nil
+	})
+
+	req := cmgen.CertificateRequest("foobar",
+		cmgen.SetCertificateRequestNamespace("default"),
+		cmgen.SetCertificateRequestCSR((func() []byte {
+			csr, _, err := cmgen.CSR(x509.ECDSA, cmgen.SetCSRDNSNames("example.com"))
+			assert.NilError(t, err)
+
+			return csr
+		})()),
+	)
+
+	provisioner, err := New(signer, v1.RequestTypeOriginECC, logr.Discard())
+	assert.NilError(t, err)
+
+	_, err = provisioner.Sign(context.Background(), req)
+	assert.Error(t, err, "certificate 9001 returned by the Cloudflare API failed verification: no certificate found")
+
+	var certErr *CertificateError
+	assert.Assert(t, errors.As(err, &certErr))
+	assert.Equal(t, certErr.ID, "9001")
+}
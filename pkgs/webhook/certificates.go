@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionregistration "k8s.io/api/admissionregistration/v1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// caValidity is how long the generated certificate authority is valid for.
+	caValidity = 10 * 365 * 24 * time.Hour
+
+	// servingValidity is how long the generated serving certificate is valid for.
+	servingValidity = 365 * 24 * time.Hour
+
+	// renewBefore is how long before expiry a stored certificate is replaced.
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// CertificateOptions configures the bootstrap and renewal of the webhook's serving
+// certificate.
+type CertificateOptions struct {
+	// Namespace is the namespace of the webhook's Service and Secret.
+	Namespace string
+
+	// ServiceName is the name of the Service fronting the webhook.
+	ServiceName string
+
+	// SecretName is the name of the Secret storing the certificate authority
+	// and serving certificate, shared between replicas.
+	SecretName string
+
+	// WebhookConfigurationName is the name of the ValidatingWebhookConfiguration
+	// to inject the certificate authority into.
+	WebhookConfigurationName string
+
+	// CertDir is the directory the webhook server reads its certificate from.
+	CertDir string
+}
+
+// BootstrapCertificates ensures a serving certificate for the webhook Service,
+// signed by a self-signed certificate authority, is stored in the Secret and
+// written to the certificate directory, and that the ValidatingWebhookConfiguration
+// trusts the certificate authority. An existing certificate in the Secret is reused
+// until it is close to expiring, so replicas share the same certificate. The
+// permissions required are granted in deploy/rbac/role-webhook.yaml, restricted to
+// the Secret and ValidatingWebhookConfiguration by name.
+func BootstrapCertificates(ctx context.Context, c client.Client, o CertificateOptions, clk clock.PassiveClock, log logr.Logger) error {
+	secret, err := ensureSecret(ctx, c, o, clk, log)
+	if err != nil {
+		return fmt.Errorf("failed to ensure webhook certificate secret: %w", err)
+	}
+
+	// Trust a renewed certificate authority before serving a certificate it signed.
+	if err := injectCABundle(ctx, c, o.WebhookConfigurationName, secret.Data["ca.crt"]); err != nil {
+		return fmt.Errorf("failed to inject webhook certificate authority: %w", err)
+	}
+
+	if err := os.MkdirAll(o.CertDir, 0o700); err != nil {
+		return err
+	}
+
+	for name, key := range map[string]string{"tls.crt": core.TLSCertKey, "tls.key": core.TLSPrivateKeyKey} {
+		path := filepath.Join(o.CertDir, name)
+
+		// Rewriting unchanged files would needlessly reload the certificate watcher.
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, secret.Data[key]) {
+			continue
+		}
+
+		if err := os.WriteFile(path, secret.Data[key], 0o600); err != nil {
+			return fmt.Errorf("failed to write webhook certificate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CertificateRotator renews the webhook's serving certificate before it expires,
+// by repeating BootstrapCertificates every interval. It runs on every replica, as
+// each serves the certificate from its own certificate directory.
+type CertificateRotator struct {
+	Client   client.Client
+	Options  CertificateOptions
+	Interval time.Duration
+	Clock    clock.WithTicker
+	Log      logr.Logger
+}
+
+var _ manager.LeaderElectionRunnable = &CertificateRotator{}
+
+// Start implements manager.Runnable, renewing the certificate until ctx is done.
+func (r *CertificateRotator) Start(ctx context.Context) error {
+	ticker := r.Clock.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := BootstrapCertificates(ctx, r.Client, r.Options, r.Clock, r.Log); err != nil {
+				r.Log.Error(err, "failed to renew webhook certificates")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (r *CertificateRotator) NeedLeaderElection() bool {
+	return false
+}
+
+// ensureSecret returns the Secret holding a valid serving certificate, generating
+// a new one if it is missing, invalid, or close to expiring.
+func ensureSecret(ctx context.Context, c client.Client, o CertificateOptions, clk clock.PassiveClock, log logr.Logger) (*core.Secret, error) {
+	dnsNames := serviceDNSNames(o.ServiceName, o.Namespace)
+
+	secret := &core.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: o.Namespace, Name: o.SecretName}, secret)
+	switch {
+	case err == nil:
+		if validCertificate(secret, dnsNames, clk.Now()) {
+			log.V(4).Info("reusing webhook serving certificate", "secret", o.SecretName)
+
+			return secret, nil
+		}
+	case apierrors.IsNotFound(err):
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: o.Namespace,
+				Name:      o.SecretName,
+			},
+			Type: core.SecretTypeTLS,
+		}
+	default:
+		return nil, err
+	}
+
+	previousCA := secret.Data["ca.crt"]
+
+	secret.Data, err = generateCertificates(dnsNames, clk.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep trusting the previous certificate authority, as other replicas serve
+	// the previous certificate until they next renew theirs.
+	if block, _ := pem.Decode(previousCA); block != nil {
+		secret.Data["ca.crt"] = append(secret.Data["ca.crt"], pem.EncodeToMemory(block)...)
+	}
+
+	log.Info("generated webhook serving certificate", "secret", o.SecretName)
+
+	if secret.ResourceVersion == "" {
+		err = c.Create(ctx, secret)
+	} else {
+		err = c.Update(ctx, secret)
+	}
+
+	// Another replica stored a certificate first; use theirs instead.
+	if apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err) {
+		secret = &core.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: o.Namespace, Name: o.SecretName}, secret); err != nil {
+			return nil, err
+		}
+
+		return secret, nil
+	}
+
+	return secret, err
+}
+
+// injectCABundle sets the CA bundle of every webhook in the configuration.
+func injectCABundle(ctx context.Context, c client.Client, name string, caBundle []byte) error {
+	config := &admissionregistration.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, config); err != nil {
+		return err
+	}
+
+	changed := false
+	for i := range config.Webhooks {
+		if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+			config.Webhooks[i].ClientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return c.Update(ctx, config)
+}
+
+// validCertificate returns true if the Secret holds a certificate authority and a
+// serving certificate for dnsNames that is not close to expiring.
+func validCertificate(secret *core.Secret, dnsNames []string, now time.Time) bool {
+	if len(secret.Data["ca.crt"]) == 0 {
+		return false
+	}
+
+	pair, err := tls.X509KeyPair(secret.Data[core.TLSCertKey], secret.Data[core.TLSPrivateKeyKey])
+	if err != nil {
+		return false
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+
+	if now.Add(renewBefore).After(cert.NotAfter) {
+		return false
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(secret.Data["ca.crt"]) {
+		return false
+	}
+
+	for _, name := range dnsNames {
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: name, CurrentTime: now}); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// generateCertificates returns Secret data containing a new self-signed
+// certificate authority, and a serving certificate for dnsNames signed by it.
+func generateCertificates(dnsNames []string, now time.Time) (map[string][]byte, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          newSerial(),
+		Subject:               pkix.Name{CommonName: "origin-ca-issuer-webhook-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: newSerial(),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(servingValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		"ca.crt":              pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		core.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		core.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// serviceDNSNames returns the DNS names the API server may use to reach the Service.
+func serviceDNSNames(service, namespace string) []string {
+	return []string{
+		fmt.Sprintf("%s.%s.svc", service, namespace),
+		service,
+		fmt.Sprintf("%s.%s", service, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+	}
+}
+
+func newSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+
+	return serial
+}
@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	admissionregistration "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakeClock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestBootstrapCertificates(t *testing.T) {
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+
+	config := &admissionregistration.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "origin-ca-issuer"},
+		Webhooks: []admissionregistration.ValidatingWebhook{
+			{Name: "originissuers.cert-manager.k8s.cloudflare.com"},
+			{Name: "clusteroriginissuers.cert-manager.k8s.cloudflare.com"},
+		},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(config).
+		Build()
+
+	opts := CertificateOptions{
+		Namespace:                "origin-ca-issuer",
+		ServiceName:              "origin-ca-issuer-webhook",
+		SecretName:               "origin-ca-issuer-webhook-tls",
+		WebhookConfigurationName: "origin-ca-issuer",
+		CertDir:                  t.TempDir(),
+	}
+
+	bootstrap := func() *corev1.Secret {
+		t.Helper()
+
+		if err := BootstrapCertificates(context.Background(), c, opts, clock, logf.Log); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		secret := &corev1.Secret{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: opts.Namespace, Name: opts.SecretName}, secret); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if !validCertificate(secret, serviceDNSNames(opts.ServiceName, opts.Namespace), clock.Now()) {
+			t.Fatal("expected a valid serving certificate")
+		}
+
+		for name, key := range map[string]string{"tls.crt": corev1.TLSCertKey, "tls.key": corev1.TLSPrivateKeyKey} {
+			data, err := os.ReadFile(filepath.Join(opts.CertDir, name))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(data, secret.Data[key]) {
+				t.Fatalf("%s does not match the secret", name)
+			}
+		}
+
+		config := &admissionregistration.ValidatingWebhookConfiguration{}
+		if err := c.Get(context.Background(), client.ObjectKey{Name: opts.WebhookConfigurationName}, config); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, wh := range config.Webhooks {
+			if diff := cmp.Diff(wh.ClientConfig.CABundle, secret.Data["ca.crt"]); diff != "" {
+				t.Fatalf("webhook %s caBundle diff: (-want +got)\n%s", wh.Name, diff)
+			}
+		}
+
+		return secret
+	}
+
+	first := bootstrap()
+
+	clock.Step(24 * time.Hour)
+	if second := bootstrap(); !bytes.Equal(first.Data[corev1.TLSCertKey], second.Data[corev1.TLSCertKey]) {
+		t.Fatal("expected the existing serving certificate to be reused")
+	}
+
+	clock.Step(servingValidity - renewBefore)
+	renewed := bootstrap()
+	if bytes.Equal(first.Data[corev1.TLSCertKey], renewed.Data[corev1.TLSCertKey]) {
+		t.Fatal("expected the expiring serving certificate to be renewed")
+	}
+	if !bytes.HasSuffix(renewed.Data["ca.crt"], first.Data["ca.crt"]) {
+		t.Fatal("expected the previous certificate authority to still be trusted")
+	}
+}
+
+func TestCertificateRotator(t *testing.T) {
+	clock := fakeClock.NewFakeClock(time.Now().Truncate(time.Second))
+
+	config := &admissionregistration.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "origin-ca-issuer"},
+		Webhooks: []admissionregistration.ValidatingWebhook{
+			{Name: "originissuers.cert-manager.k8s.cloudflare.com"},
+		},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(config).
+		Build()
+
+	r := &CertificateRotator{
+		Client: c,
+		Options: CertificateOptions{
+			Namespace:                "origin-ca-issuer",
+			ServiceName:              "origin-ca-issuer-webhook",
+			SecretName:               "origin-ca-issuer-webhook-tls",
+			WebhookConfigurationName: "origin-ca-issuer",
+			CertDir:                  t.TempDir(),
+		},
+		Interval: time.Hour,
+		Clock:    clock,
+		Log:      logf.Log,
+	}
+
+	if err := BootstrapCertificates(context.Background(), c, r.Options, clock, logf.Log); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	certPath := filepath.Join(r.Options.CertDir, "tls.crt")
+	first, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Start(ctx) }()
+
+	for !clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Step(servingValidity - renewBefore + time.Minute)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		current, err := os.ReadFile(certPath)
+		if err == nil && len(current) > 0 && !bytes.Equal(current, first) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected the expiring serving certificate to be renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if r.NeedLeaderElection() {
+		t.Fatal("expected the rotator to run on every replica")
+	}
+}
+
+func TestValidCertificate(t *testing.T) {
+	now := time.Now()
+	dnsNames := serviceDNSNames("origin-ca-issuer-webhook", "origin-ca-issuer")
+
+	data, err := generateCertificates(dnsNames, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	other, err := generateCertificates(dnsNames, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     map[string][]byte
+		dnsNames []string
+		now      time.Time
+		valid    bool
+	}{
+		{
+			name:     "valid",
+			data:     data,
+			dnsNames: dnsNames,
+			now:      now,
+			valid:    true,
+		},
+		{
+			name:     "empty",
+			data:     map[string][]byte{},
+			dnsNames: dnsNames,
+			now:      now,
+		},
+		{
+			name:     "different service",
+			data:     data,
+			dnsNames: serviceDNSNames("other", "origin-ca-issuer"),
+			now:      now,
+		},
+		{
+			name:     "expiring",
+			data:     data,
+			dnsNames: dnsNames,
+			now:      now.Add(servingValidity - renewBefore + time.Minute),
+		},
+		{
+			name: "untrusted authority",
+			data: map[string][]byte{
+				"ca.crt":                other["ca.crt"],
+				corev1.TLSCertKey:       data[corev1.TLSCertKey],
+				corev1.TLSPrivateKeyKey: data[corev1.TLSPrivateKeyKey],
+			},
+			dnsNames: dnsNames,
+			now:      now,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{Data: tt.data}
+			if got := validCertificate(secret, tt.dnsNames, tt.now); got != tt.valid {
+				t.Fatalf("expected valid to be %t, got %t", tt.valid, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package webhook provides a validating admission webhook for OriginIssuer and
+// ClusterOriginIssuer resources, along with bootstrapping of its serving certificate.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// IssuerValidator validates OriginIssuers and ClusterOriginIssuers on admission,
+// rejecting resources the controller would fail to reconcile.
+type IssuerValidator struct {
+	// AllowEndpoints accepts issuers overriding the Cloudflare API endpoint.
+	AllowEndpoints bool
+}
+
+var _ admission.CustomValidator = &IssuerValidator{}
+
+// SetupWithManager registers the validating webhooks, validating with v, with the
+// manager's webhook server.
+func SetupWithManager(mgr manager.Manager, v *IssuerValidator) error {
+	for _, obj := range []runtime.Object{&v1.OriginIssuer{}, &v1.ClusterOriginIssuer{}} {
+		err := builder.WebhookManagedBy(mgr).
+			For(obj).
+			WithValidator(v).
+			Complete()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateCreate validates a newly created issuer.
+func (v *IssuerValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate validates the updated issuer.
+func (v *IssuerValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete allows all issuers to be deleted.
+func (v *IssuerValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *IssuerValidator) validate(obj runtime.Object) error {
+	iss, ok := obj.(v1.GenericIssuer)
+	if !ok {
+		return fmt.Errorf("unexpected object of type %T", obj)
+	}
+
+	spec := iss.GetSpec()
+	err := spec.Validate()
+
+	if spec.Endpoint != "" && !v.AllowEndpoints {
+		err = errors.Join(err, fmt.Errorf("spec.endpoint is not allowed, overriding the Cloudflare API endpoint is not enabled for this controller"))
+	}
+
+	return err
+}
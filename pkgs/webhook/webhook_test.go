@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/cloudflare/origin-ca-issuer/pkgs/apis/v1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestIssuerValidator(t *testing.T) {
+	spec := v1.OriginIssuerSpec{
+		RequestType: v1.RequestTypeOriginECC,
+		Auth: v1.OriginIssuerAuthentication{
+			TokenRef: v1.SecretKeySelector{Name: "api-token", Key: "token"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		obj            runtime.Object
+		allowEndpoints bool
+		error          string
+	}{
+		{
+			name: "valid OriginIssuer",
+			obj: &v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Spec:       spec,
+			},
+		},
+		{
+			name: "valid ClusterOriginIssuer",
+			obj: &v1.ClusterOriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec:       spec,
+			},
+		},
+		{
+			name: "invalid OriginIssuer",
+			obj: &v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: "OriginDSA",
+					Auth:        spec.Auth,
+				},
+			},
+			error: `spec.requestType has invalid value "OriginDSA"`,
+		},
+		{
+			name: "invalid ClusterOriginIssuer",
+			obj: &v1.ClusterOriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeOriginRSA,
+				},
+			},
+			error: "one of spec.auth.serviceKeyRef or spec.auth.tokenRef must be specified",
+		},
+		{
+			name: "allowed endpoint",
+			obj: &v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeOriginECC,
+					Auth:        spec.Auth,
+					Endpoint:    "https://api.example.com",
+				},
+			},
+			allowEndpoints: true,
+		},
+		{
+			name: "endpoint not allowed",
+			obj: &v1.ClusterOriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeOriginECC,
+					Auth:        spec.Auth,
+					Endpoint:    "https://api.example.com",
+				},
+			},
+			error: "spec.endpoint is not allowed, overriding the Cloudflare API endpoint is not enabled for this controller",
+		},
+		{
+			name: "invalid endpoint not allowed",
+			obj: &v1.OriginIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Spec: v1.OriginIssuerSpec{
+					RequestType: v1.RequestTypeOriginECC,
+					Auth:        spec.Auth,
+					Endpoint:    "ftp://api.example.com",
+				},
+			},
+			error: `spec.endpoint has invalid value "ftp://api.example.com": scheme must be https or http` + "\n" +
+				"spec.endpoint is not allowed, overriding the Cloudflare API endpoint is not enabled for this controller",
+		},
+		{
+			name:  "unexpected object",
+			obj:   &corev1.Secret{},
+			error: "unexpected object of type *v1.Secret",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v := &IssuerValidator{AllowEndpoints: tt.allowEndpoints}
+
+			_, createErr := v.ValidateCreate(context.Background(), tt.obj)
+			_, updateErr := v.ValidateUpdate(context.Background(), tt.obj.DeepCopyObject(), tt.obj)
+
+			for op, err := range map[string]error{"create": createErr, "update": updateErr} {
+				if tt.error == "" {
+					if err != nil {
+						t.Fatalf("%s: unexpected error: %s", op, err)
+					}
+					continue
+				}
+
+				if err == nil {
+					t.Fatalf("%s: expected error", op)
+				}
+				if diff := cmp.Diff(err.Error(), tt.error); diff != "" {
+					t.Fatalf("%s: diff: (-want +got)\n%s", op, diff)
+				}
+			}
+
+			if _, err := v.ValidateDelete(context.Background(), tt.obj); err != nil {
+				t.Fatalf("delete: unexpected error: %s", err)
+			}
+		})
+	}
+}